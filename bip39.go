@@ -0,0 +1,70 @@
+package dpass
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//go:embed bip39_english.txt
+var bip39EnglishTxt string
+
+var (
+	bip39English     []string
+	bip39EnglishOnce sync.Once
+)
+
+const DefaultMnemonicBits = 256
+
+// BIP39English returns the English word list of BIP 39, used by ModeBIP39
+func BIP39English() []string {
+	bip39EnglishOnce.Do(func() {
+		var err error
+		bip39English, err = ParseWordlist(strings.NewReader(bip39EnglishTxt))
+		if err != nil {
+			panic(err)
+		}
+	})
+	return bip39English
+}
+
+// Mnemonic returns the BIP 39 mnemonic of 128 to 256 bits of entropy, in steps
+// of 32 bits
+func Mnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("Mnemonic entropy must be 128, 160, 192, 224 or 256 bits")
+	}
+	// The checksum is the first bits/32 bits of the sha256 of the entropy
+	sum := sha256.Sum256(entropy)
+	b := append(append([]byte{}, entropy...), sum[0])
+
+	wl := BIP39English()
+	ws := make([]string, (bits+bits/32)/11)
+	for i := range ws {
+		n := 0
+		for j := i * 11; j < i*11+11; j++ {
+			n = n<<1 | int(b[j/8]>>(7-uint(j%8))&1)
+		}
+		ws[i] = wl[n]
+	}
+	return strings.Join(ws, " "), nil
+}
+
+// genBIP39V2 returns the mnemonic of MnemonicBits of entropy from the hashStream
+func genBIP39V2(g *GenOpts, h *hashStream) (string, error) {
+	if err := g.onlyChars(false); err != nil {
+		return "", err
+	}
+	if g.MnemonicBits%8 != 0 {
+		return "", fmt.Errorf("Mnemonic entropy must be 128, 160, 192, 224 or 256 bits")
+	}
+	e := make([]byte, g.MnemonicBits/8)
+	if _, err := Mnemonic(e); err != nil {
+		return "", err
+	}
+	h.Read(e)
+	return Mnemonic(e)
+}
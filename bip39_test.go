@@ -0,0 +1,74 @@
+package dpass
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMnemonicVectors(t *testing.T) {
+	assert := assert.New(t)
+	wl := BIP39English()
+	assert.Len(wl, 2048)
+	assert.Equal("abandon", wl[0])
+	assert.Equal("zoo", wl[2047])
+
+	// Test vectors from the BIP 39 reference implementation
+	for e, m := range map[string]string{
+		"00000000000000000000000000000000":                                 "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f":                                 "legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"80808080808080808080808080808080":                                 "letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		"ffffffffffffffffffffffffffffffff":                                 "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		"9e885d952ad362caeb4efe34a8e91bd2":                                 "ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+		"0000000000000000000000000000000000000000000000000000000000000000": strings.Repeat("abandon ", 23) + "art",
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff": strings.Repeat("zoo ", 23) + "vote",
+	} {
+		b, _ := hex.DecodeString(e)
+		s, err := Mnemonic(b)
+		assert.NoError(err)
+		assert.Equal(m, s, e)
+	}
+
+	_, err := Mnemonic(make([]byte, 15))
+	assert.Error(err)
+	_, err = Mnemonic(make([]byte, 18))
+	assert.Error(err)
+}
+
+func TestBIP39(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	g.Mode = ModeBIP39
+	assert.NoError(g.HashPw([]byte(testPw)))
+
+	g.MnemonicBits = 128
+	m, err := g.GenPW()
+	assert.NoError(err)
+	assert.Equal("account drink indoor notice melt produce lunch speed ill outer nation garlic", m)
+
+	for bits, words := range map[uint64]int{128: 12, 160: 15, 192: 18, 224: 21, 256: 24} {
+		g.MnemonicBits = bits
+		m, err := g.GenPW()
+		assert.NoError(err)
+		assert.Len(strings.Fields(m), words)
+		n, err := g.GenPW()
+		assert.NoError(err)
+		assert.Equal(m, n)
+	}
+
+	g.MnemonicBits = 256
+	m, err = g.GenPW()
+	assert.NoError(err)
+	g.Iteration = 1
+	n, err := g.GenPW()
+	assert.NoError(err)
+	assert.NotEqual(m, n)
+
+	for _, bits := range []uint64{0, 100, 129, 512} {
+		g.MnemonicBits = bits
+		_, err = g.GenPW()
+		assert.Error(err, "%d bits", bits)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/clinta/dpass"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var agentCommand = cli.Command{
+	Name:  "agent",
+	Usage: "Serve derived SSH keys with an ssh-agent on a unix socket",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "socket, a",
+			Usage: "Path of the agent socket",
+			Value: filepath.Join(os.Getenv("HOME"), ".dpass", "agent.sock"),
+		},
+		cli.StringSliceFlag{
+			Name:  "key, k",
+			Usage: "Key to serve as domain:username or domain:username:iteration. May be repeated",
+		},
+		cli.DurationFlag{
+			Name:  "timeout, t",
+			Usage: "Drop the keys and stop the agent after this long, 0 to run until stopped",
+		},
+		kdfFlag,
+		argon2TimeFlag,
+		argon2MemoryFlag,
+		argon2ThreadsFlag,
+	},
+	Action: Agent,
+}
+
+// parseAgentKey parses a key flag of domain:username[:iteration] into options
+// of ModeSSH
+func parseAgentKey(s string) (*dpass.GenOpts, error) {
+	f := strings.Split(s, ":")
+	if len(f) < 2 || len(f) > 3 || f[0] == "" || f[1] == "" {
+		return nil, fmt.Errorf("Key %q must be domain:username or domain:username:iteration", s)
+	}
+	g := dpass.NewGenOpts(f[1], f[0])
+	g.Mode = dpass.ModeSSH
+	if len(f) == 3 {
+		i, err := strconv.ParseUint(f[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Key %q iteration: %v", s, err)
+		}
+		g.Iteration = i
+	}
+	return g, nil
+}
+
+// dropAgent is a keyring which forgets its keys when it is locked, so they
+// only have to be in memory while the agent is unlocked
+type dropAgent struct {
+	agent.Agent
+}
+
+func (a dropAgent) Lock(passphrase []byte) error {
+	if err := a.RemoveAll(); err != nil {
+		return err
+	}
+	return a.Agent.Lock(passphrase)
+}
+
+// removeStaleSocket removes the socket left at sock by an agent which did not
+// stop cleanly. A socket which is still served, or any other file, is left alone.
+func removeStaleSocket(sock string) error {
+	fi, err := os.Lstat(sock)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", sock)
+	}
+	if c, err := net.Dial("unix", sock); err == nil {
+		c.Close()
+		return fmt.Errorf("An agent is already listening on %s", sock)
+	}
+	return os.Remove(sock)
+}
+
+func Agent(ctx *cli.Context) error {
+	kdf, err := kdfFromCtx(ctx)
+	if err != nil {
+		return err
+	}
+	var gs []*dpass.GenOpts
+	for _, k := range ctx.StringSlice("key") {
+		g, err := parseAgentKey(k)
+		if err != nil {
+			return err
+		}
+		g.KDF = kdf
+		gs = append(gs, g)
+	}
+	if len(gs) == 0 {
+		return fmt.Errorf("At least one key required")
+	}
+
+	sock := ctx.String("socket")
+	if err := os.MkdirAll(filepath.Dir(sock), 0700); err != nil {
+		return err
+	}
+	if err := removeStaleSocket(sock); err != nil {
+		return err
+	}
+
+	bytePassword, err := readPw()
+	if err != nil {
+		return err
+	}
+	k, err := gs[0].KDFParams()
+	if err != nil {
+		return err
+	}
+	m, err := dpass.NewMasterKey(bytePassword, k)
+	if err != nil {
+		return err
+	}
+
+	keyring := agent.NewKeyring()
+	for _, g := range gs {
+		pk, err := m.SSHKey(g)
+		if err != nil {
+			return err
+		}
+		if err := keyring.Add(agent.AddedKey{
+			PrivateKey: pk,
+			Comment:    g.Username + "@" + g.Domain,
+		}); err != nil {
+			return err
+		}
+	}
+	a := dropAgent{keyring}
+
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	if err := os.Chmod(sock, 0600); err != nil {
+		return err
+	}
+
+	// Closing the listener stops the agent, and removes the socket
+	stop := func() {
+		a.RemoveAll()
+		l.Close()
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		stop()
+	}()
+	if t := ctx.Duration("timeout"); t > 0 {
+		time.AfterFunc(t, stop)
+	}
+
+	fmt.Printf("SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", sock)
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			a.RemoveAll()
+			return nil
+		}
+		go func() {
+			defer c.Close()
+			agent.ServeAgent(a, c)
+		}()
+	}
+}
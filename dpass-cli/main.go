@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/clinta/dpass"
 	"github.com/urfave/cli"
@@ -16,93 +20,9 @@ func main() {
 	app.Name = dpass.AppName
 	app.Usage = "Deterministic Password Generator"
 	app.Version = version
-	app.Flags = []cli.Flag{
-		cli.StringFlag{
-			Name:  "domain, d",
-			Usage: "Domain to create a password for",
-		},
-		cli.StringFlag{
-			Name:  "username, u",
-			Usage: "Username for the domain",
-		},
-		cli.Uint64Flag{
-			Name:  "iteration, i",
-			Usage: "Iteration of the password",
-			Value: 0,
-		},
-		cli.Uint64Flag{
-			Name:  "characters, c",
-			Usage: "Number of characters to make the password",
-			Value: dpass.DefaultLength,
-		},
-		cli.Uint64Flag{
-			Name:  "pw-version, pwv",
-			Usage: "Version of the password generation algorithm to use",
-			Value: dpass.LatestGenVersion,
-		},
-		cli.Uint64Flag{
-			Name:  "symbols, s",
-			Usage: "Minimum number of symbol characters to include.",
-			Value: 0,
-		},
-		cli.IntFlag{
-			Name:  "max-symbols, ms",
-			Usage: "Maximum number of symbol characters to include. -1 means no max. 0 to disable symbols.",
-			Value: dpass.DefaultMax,
-		},
-		cli.Uint64Flag{
-			Name:  "numbers, n",
-			Usage: "Minimum number of digits to include.",
-			Value: 0,
-		},
-		cli.IntFlag{
-			Name:  "max-numbers, mn",
-			Usage: "Maximum number of digits to include. -1 means no max",
-			Value: dpass.DefaultMax,
-		},
-		cli.Uint64Flag{
-			Name:  "lowers, l",
-			Usage: "Minimum number of lowercase letters.",
-			Value: 0,
-		},
-		cli.IntFlag{
-			Name:  "max-lowers, ml",
-			Usage: "Maximum number of lowercase letters to include. -1 means no max",
-			Value: dpass.DefaultMax,
-		},
-		cli.Uint64Flag{
-			Name:  "uppers, U",
-			Usage: "Minimum number of uppercase letters.",
-			Value: 0,
-		},
-		cli.IntFlag{
-			Name:  "max-uppers, mU",
-			Usage: "Maximum number of uppercase letters to include. -1 means no max",
-			Value: dpass.DefaultMax,
-		},
-		cli.StringFlag{
-			Name:  "symbol-set, ss",
-			Usage: "Set of symbols to include",
-			Value: dpass.DefaultSymbolSet,
-		},
-		cli.BoolFlag{
-			Name:  "json, j",
-			Usage: "Output json encoded options",
-		},
-		cli.BoolFlag{
-			Name:  "identifier, id",
-			Usage: "Output the Options ID that can be used to index stored options",
-		},
-		cli.StringFlag{
-			Name:  "json-in, ji",
-			Usage: "Input json options",
-		},
-		cli.BoolFlag{
-			Name:  "quiet, q",
-			Usage: "Print only the password to stdout",
-		},
-	}
+	app.Flags = genFlags
 	app.Action = Run
+	app.Commands = append(storeCommands, totpCommand, agentCommand)
 	err := app.Run(os.Args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s", err.Error())
@@ -110,14 +30,228 @@ func main() {
 	}
 }
 
-func Run(ctx *cli.Context) error {
+// genFlags are the flags which build the generation options
+var genFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "domain, d",
+		Usage: "Domain to create a password for",
+	},
+	cli.StringFlag{
+		Name:  "username, u",
+		Usage: "Username for the domain",
+	},
+	cli.Uint64Flag{
+		Name:  "iteration, i",
+		Usage: "Iteration of the password",
+		Value: 0,
+	},
+	cli.Uint64Flag{
+		Name:  "characters, c",
+		Usage: "Number of characters to make the password",
+		Value: dpass.DefaultLength,
+	},
+	cli.Uint64Flag{
+		Name:  "pw-version, pwv",
+		Usage: "Version of the password generation algorithm to use",
+		Value: dpass.LatestGenVersion,
+	},
+	cli.Uint64Flag{
+		Name:  "symbols, s",
+		Usage: "Minimum number of symbol characters to include.",
+		Value: 0,
+	},
+	cli.IntFlag{
+		Name:  "max-symbols, ms",
+		Usage: "Maximum number of symbol characters to include. -1 means no max. 0 to disable symbols.",
+		Value: dpass.DefaultMax,
+	},
+	cli.Uint64Flag{
+		Name:  "numbers, n",
+		Usage: "Minimum number of digits to include.",
+		Value: 0,
+	},
+	cli.IntFlag{
+		Name:  "max-numbers, mn",
+		Usage: "Maximum number of digits to include. -1 means no max",
+		Value: dpass.DefaultMax,
+	},
+	cli.Uint64Flag{
+		Name:  "lowers, l",
+		Usage: "Minimum number of lowercase letters.",
+		Value: 0,
+	},
+	cli.IntFlag{
+		Name:  "max-lowers, ml",
+		Usage: "Maximum number of lowercase letters to include. -1 means no max",
+		Value: dpass.DefaultMax,
+	},
+	cli.Uint64Flag{
+		Name:  "uppers, U",
+		Usage: "Minimum number of uppercase letters.",
+		Value: 0,
+	},
+	cli.IntFlag{
+		Name:  "max-uppers, mU",
+		Usage: "Maximum number of uppercase letters to include. -1 means no max",
+		Value: dpass.DefaultMax,
+	},
+	cli.StringFlag{
+		Name:  "symbol-set, ss",
+		Usage: "Set of symbols to include",
+		Value: dpass.DefaultSymbolSet,
+	},
+	cli.StringFlag{
+		Name:  "symbol-profile, sp",
+		Usage: "Use the symbols which are safe in a context instead of --symbol-set: " + strings.Join(dpass.SymbolProfileNames(), ", "),
+	},
+	kdfFlag,
+	argon2TimeFlag,
+	argon2MemoryFlag,
+	argon2ThreadsFlag,
+	cli.StringSliceFlag{
+		Name:  "class",
+		Usage: "Additional character class as name:min:max:characters, such as brackets:1:-1:()[]. May be repeated",
+	},
+	cli.StringSliceFlag{
+		Name:  "block-class",
+		Usage: "Additional character class of unicode blocks as name:min:max:block[,block], such as greek:1:-1:greek. Blocks are latin1, latin-ext-a, greek, cyrillic and emoji. May be repeated",
+	},
+	cli.BoolFlag{
+		Name:  "exclude-ambiguous, xa",
+		Usage: "Exclude characters which are easily confused, such as 0O1lI|;:",
+	},
+	cli.StringFlag{
+		Name:  "exclude, x, banned",
+		Usage: "Characters to exclude from the password, even if they are in the symbol profile",
+	},
+	cli.Uint64Flag{
+		Name:  "max-length",
+		Usage: "Maximum length of the password, the length is chosen between --characters and this. 0 for a fixed length",
+	},
+	cli.Uint64Flag{
+		Name:  "min-classes",
+		Usage: "Minimum number of character classes to use, chosen at random from the classes without a minimum",
+	},
+	cli.Uint64Flag{
+		Name:  "max-repeat",
+		Usage: "Maximum identical characters in a row, 0 for no limit",
+	},
+	cli.Uint64Flag{
+		Name:  "max-sequence",
+		Usage: "Maximum length of a sequence such as abc or 987, 0 for no limit",
+	},
+	cli.StringFlag{
+		Name:  "positions",
+		Usage: "Class of each character from the start: x any, n number, u upper, l lower, s symbol, a letter, A letter or number. For example a for a letter first",
+	},
+	cli.StringFlag{
+		Name:  "positions-end",
+		Usage: "Class of each character up to the end, like --positions. For example A for no symbol last",
+	},
+	cli.StringFlag{
+		Name:  "mode, m",
+		Usage: "Kind of password to generate: chars, words for a passphrase, pin, pronounceable, key for raw key material, ssh for an ed25519 key, x25519 for an age or WireGuard key, totp for a TOTP secret or bip39 for a mnemonic",
+		Value: "chars",
+	},
+	cli.Uint64Flag{
+		Name:  "words, w",
+		Usage: "Number of words in a passphrase",
+		Value: 6,
+	},
+	cli.StringFlag{
+		Name:  "word-sep",
+		Usage: "Separator between the words of a passphrase",
+		Value: "-",
+	},
+	cli.StringFlag{
+		Name:  "word-case",
+		Usage: "Capitalization of passphrase words: lower, upper, title or random",
+		Value: "lower",
+	},
+	cli.StringFlag{
+		Name:  "word-append",
+		Usage: "Append to the passphrase: none, digit, symbol or both",
+		Value: "none",
+	},
+	wordlistFlag,
+	cli.Uint64Flag{
+		Name:  "key-bytes",
+		Usage: "Number of bytes of a key",
+		Value: dpass.DefaultKeyBytes,
+	},
+	cli.StringFlag{
+		Name:  "key-encoding",
+		Usage: "Encoding of a key: hex, base64, base64url, base32 or raw. Raw keys must be written with --out",
+		Value: "hex",
+	},
+	cli.StringFlag{
+		Name:  "x25519-format",
+		Usage: "Format of an x25519 key: age or wireguard",
+		Value: "age",
+	},
+	wgInterfaceFlag,
+	wgAddressFlag,
+	wgListenPortFlag,
+	cli.Uint64Flag{
+		Name:  "otp-period",
+		Usage: "Seconds each TOTP code is valid for",
+		Value: dpass.DefaultOTPPeriod,
+	},
+	cli.Uint64Flag{
+		Name:  "otp-digits",
+		Usage: "Digits of each TOTP code, 6 to 8",
+		Value: dpass.DefaultOTPDigits,
+	},
+	cli.StringFlag{
+		Name:  "otp-algorithm",
+		Usage: "Hash algorithm of the TOTP codes: SHA1, SHA256 or SHA512",
+		Value: "SHA1",
+	},
+	cli.Uint64Flag{
+		Name:  "mnemonic-bits",
+		Usage: "Bits of entropy of a bip39 mnemonic: 128, 160, 192, 224 or 256",
+		Value: dpass.DefaultMnemonicBits,
+	},
+	outFlag,
+	cli.BoolFlag{
+		Name:  "pin-block-common",
+		Usage: "Skip commonly used PINs",
+	},
+	cli.StringSliceFlag{
+		Name:  "pin-block",
+		Usage: "PIN to skip, may be repeated",
+	},
+	cli.StringFlag{
+		Name:  "template",
+		Usage: "Template for pronounceable passwords, such as CvccvcnoCvcv. V/v vowel, C/c consonant, A upper, a letter, n number, o symbol, x any",
+	},
+	cli.BoolFlag{
+		Name:  "json, j",
+		Usage: "Output json encoded options",
+	},
+	cli.BoolFlag{
+		Name:  "identifier, id",
+		Usage: "Output the Options ID that can be used to index stored options",
+	},
+	cli.StringFlag{
+		Name:  "json-in, ji",
+		Usage: "Input json options",
+	},
+	cli.BoolFlag{
+		Name:  "quiet, q",
+		Usage: "Print only the password to stdout",
+	},
+}
+
+// optsFromCtx builds the generation options from the genFlags
+func optsFromCtx(ctx *cli.Context) (*dpass.GenOpts, error) {
 	g := dpass.NewGenOpts(ctx.String("username"), ctx.String("domain"))
 	var err error
 
 	if ctx.String("json-in") != "" {
 		g, err = dpass.FromJSON([]byte(ctx.String("json-in")))
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -133,12 +267,388 @@ func Run(ctx *cli.Context) error {
 	g.Symbols = ctx.Uint64("symbols")
 	g.MaxSymbols = ctx.Int("max-symbols")
 	g.SymbolSet = ctx.String("symbol-set")
+	if p := ctx.String("symbol-profile"); p != "" {
+		if ctx.IsSet("symbol-set") {
+			return nil, fmt.Errorf("Only one of symbol-set and symbol-profile may be set")
+		}
+		if err := g.SetSymbolProfile(p); err != nil {
+			return nil, err
+		}
+	}
+
+	g.ExcludeAmbiguous = ctx.Bool("exclude-ambiguous")
+	g.Exclude = ctx.String("exclude")
+	g.MaxLength = ctx.Uint64("max-length")
+	g.MinClasses = ctx.Uint64("min-classes")
+	g.MaxRepeat = ctx.Uint64("max-repeat")
+	g.MaxSequence = ctx.Uint64("max-sequence")
+	g.Positions = ctx.String("positions")
+	g.PositionsEnd = ctx.String("positions-end")
+
+	for _, c := range ctx.StringSlice("class") {
+		cc, err := parseClass(c)
+		if err != nil {
+			return nil, err
+		}
+		g.Classes = append(g.Classes, cc)
+	}
+	for _, c := range ctx.StringSlice("block-class") {
+		cc, err := parseClass(c)
+		if err != nil {
+			return nil, err
+		}
+		cc.Blocks, cc.Chars = strings.Split(cc.Chars, ","), ""
+		g.Classes = append(g.Classes, cc)
+	}
+
+	switch ctx.String("mode") {
+	case "chars":
+		g.Mode = dpass.ModeChars
+	case dpass.ModeWords:
+		g.Mode = dpass.ModeWords
+		g.Words = ctx.Uint64("words")
+		g.WordSep = ctx.String("word-sep")
+		g.WordCase = ctx.String("word-case")
+		if g.WordCase == "lower" {
+			g.WordCase = dpass.WordCaseLower
+		}
+		g.WordAppend = ctx.String("word-append")
+		if g.WordAppend == "none" {
+			g.WordAppend = dpass.WordAppendNone
+		}
+	case dpass.ModePIN:
+		g.Mode = dpass.ModePIN
+		if !ctx.IsSet("characters") {
+			g.Length = dpass.DefaultPINLength
+		}
+		g.PINBlockCommon = ctx.Bool("pin-block-common")
+		g.PINBlocklist = ctx.StringSlice("pin-block")
+	case dpass.ModePronounceable:
+		g.Mode = dpass.ModePronounceable
+		g.PronounceTemplate = ctx.String("template")
+	case dpass.ModeKey:
+		g.Mode = dpass.ModeKey
+		g.KeyBytes = ctx.Uint64("key-bytes")
+		g.KeyEncoding = ctx.String("key-encoding")
+		if g.KeyEncoding == "hex" {
+			g.KeyEncoding = dpass.KeyEncodingHex
+		}
+		if g.KeyEncoding == dpass.KeyEncodingRaw && ctx.String("out") == "" {
+			return nil, errRawOut
+		}
+	case dpass.ModeSSH:
+		g.Mode = dpass.ModeSSH
+	case dpass.ModeTOTP:
+		g.Mode = dpass.ModeTOTP
+		g.OTPPeriod = ctx.Uint64("otp-period")
+		g.OTPDigits = ctx.Uint64("otp-digits")
+		g.OTPAlgorithm = strings.ToUpper(ctx.String("otp-algorithm"))
+		if g.OTPAlgorithm == "SHA1" {
+			g.OTPAlgorithm = dpass.OTPAlgorithmSHA1
+		}
+	case dpass.ModeBIP39:
+		g.Mode = dpass.ModeBIP39
+		g.MnemonicBits = ctx.Uint64("mnemonic-bits")
+	case dpass.ModeX25519:
+		g.Mode = dpass.ModeX25519
+		g.X25519Format = ctx.String("x25519-format")
+		if g.X25519Format == "age" {
+			g.X25519Format = dpass.X25519FormatAge
+		}
+	default:
+		return nil, fmt.Errorf("Unknown mode %s", ctx.String("mode"))
+	}
+	if err := setWordlist(ctx, g); err != nil {
+		return nil, err
+	}
+
+	if g.KDF, err = kdfFromCtx(ctx); err != nil {
+		return nil, err
+	}
 
 	if g.Domain == "" {
-		return fmt.Errorf("Domain required")
+		return nil, fmt.Errorf("Domain required")
 	}
 	if g.Username == "" {
-		return fmt.Errorf("Username required")
+		return nil, fmt.Errorf("Username required")
+	}
+	return g, nil
+}
+
+// parseClass parses a class flag of name:min:max:characters
+func parseClass(s string) (dpass.CharClass, error) {
+	f := strings.SplitN(s, ":", 4)
+	if len(f) != 4 {
+		return dpass.CharClass{}, fmt.Errorf("Class %q must be name:min:max:characters", s)
+	}
+	min, err := strconv.ParseUint(f[1], 10, 64)
+	if err != nil {
+		return dpass.CharClass{}, fmt.Errorf("Class %q min: %v", s, err)
+	}
+	max, err := strconv.Atoi(f[2])
+	if err != nil {
+		return dpass.CharClass{}, fmt.Errorf("Class %q max: %v", s, err)
+	}
+	return dpass.CharClass{Name: f[0], Chars: f[3], Min: min, Max: max}, nil
+}
+
+var (
+	kdfFlag = cli.StringFlag{
+		Name:  "kdf",
+		Usage: "KDF to hash the master password with, scrypt or argon2id. Defaults to the KDF of the pw-version",
+	}
+	argon2TimeFlag = cli.UintFlag{
+		Name:  "argon2-time",
+		Usage: "Argon2id iterations",
+		Value: uint(dpass.DefaultArgon2id.Time),
+	}
+	argon2MemoryFlag = cli.UintFlag{
+		Name:  "argon2-memory",
+		Usage: "Argon2id memory in KiB",
+		Value: uint(dpass.DefaultArgon2id.Memory),
+	}
+	argon2ThreadsFlag = cli.UintFlag{
+		Name:  "argon2-threads",
+		Usage: "Argon2id threads",
+		Value: uint(dpass.DefaultArgon2id.Threads),
+	}
+)
+
+// kdfFromCtx returns the KDF set by the kdf flags, or nil for the KDF of the
+// pw-version
+func kdfFromCtx(ctx *cli.Context) (*dpass.KDFParams, error) {
+	switch ctx.String("kdf") {
+	case "":
+		return nil, nil
+	case dpass.KDFScrypt:
+		k := dpass.DefaultKDF
+		return &k, nil
+	case dpass.KDFArgon2id:
+		k := dpass.DefaultArgon2id
+		k.Time = uint32(ctx.Uint("argon2-time"))
+		k.Memory = uint32(ctx.Uint("argon2-memory"))
+		k.Threads = uint8(ctx.Uint("argon2-threads"))
+		return &k, nil
+	}
+	return nil, fmt.Errorf("Unknown KDF %s", ctx.String("kdf"))
+}
+
+var wordlistFlag = cli.StringFlag{
+	Name:  "wordlist",
+	Usage: "File of passphrase words, one per line. Defaults to the EFF large word list",
+}
+
+// setWordlist reads the wordlist flag into the options. New options record the
+// hash of the list, saved options must be given the same list.
+func setWordlist(ctx *cli.Context, g *dpass.GenOpts) error {
+	if ctx.String("wordlist") == "" {
+		return nil
+	}
+	f, err := os.Open(ctx.String("wordlist"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ws, err := dpass.ParseWordlist(f)
+	if err != nil {
+		return err
+	}
+	if g.WordlistHash != "" {
+		return g.LoadWordlist(ws)
+	}
+	g.SetWordlist(ws)
+	return nil
+}
+
+var errRawOut = fmt.Errorf("Raw keys must be written to a file with --out")
+
+var outFlag = cli.StringFlag{
+	Name:  "out, o",
+	Usage: "Write the password or key to this file instead of printing it. SSH public keys are written to the file with .pub appended",
+}
+
+// The WireGuard flags only change how a key is printed, so they are not saved
+var (
+	wgInterfaceFlag = cli.BoolFlag{
+		Name:  "wg-interface",
+		Usage: "Output a WireGuard key as an [Interface] section",
+	}
+	wgAddressFlag = cli.StringFlag{
+		Name:  "wg-address",
+		Usage: "Address of the WireGuard [Interface] section",
+	}
+	wgListenPortFlag = cli.UintFlag{
+		Name:  "wg-listen-port",
+		Usage: "ListenPort of the WireGuard [Interface] section",
+	}
+)
+
+// x25519Text returns the private and public keys of options of ModeX25519 in
+// their format. Age identities are formatted like age-keygen, and WireGuard
+// keys as an [Interface] section if wg-interface is set.
+func x25519Text(ctx *cli.Context, g *dpass.GenOpts) (string, string, error) {
+	priv, pub, err := g.X25519()
+	if err != nil {
+		return "", "", err
+	}
+	if g.X25519Format == dpass.X25519FormatWireGuard {
+		if ctx.Bool("wg-interface") {
+			return dpass.WireGuardInterface(priv, ctx.String("wg-address"), uint16(ctx.Uint("wg-listen-port"))),
+				dpass.WireGuardKey(pub), nil
+		}
+		return dpass.WireGuardKey(priv) + "\n", dpass.WireGuardKey(pub), nil
+	}
+	id, err := g.GenPW()
+	if err != nil {
+		return "", "", err
+	}
+	r, err := dpass.AgeRecipient(pub)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("# public key: %s\n%s\n", r, id), r, nil
+}
+
+// output prints the password or key of the options, or writes it to the out
+// flag file
+func output(ctx *cli.Context, g *dpass.GenOpts) error {
+	if ctx.String("out") != "" {
+		return writeOut(ctx, g)
+	}
+	if g.Mode == dpass.ModeKey && g.KeyEncoding == dpass.KeyEncodingRaw {
+		return errRawOut
+	}
+	if g.Mode == dpass.ModeX25519 {
+		priv, pub, err := x25519Text(ctx, g)
+		if err != nil {
+			return err
+		}
+		fmt.Print(priv)
+		if !ctx.Bool("quiet") && g.X25519Format == dpass.X25519FormatWireGuard {
+			fmt.Printf("Public: %s\n", pub)
+		}
+		return nil
+	}
+	if g.Mode == dpass.ModeSSH {
+		k, err := g.SSHPrivateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(k))
+	}
+	if g.Mode == dpass.ModeTOTP && !ctx.Bool("quiet") {
+		s, err := g.OTPSecret()
+		if err != nil {
+			return err
+		}
+		u, err := g.OTPURI(s)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Secret: %s\nURI: %s\n", dpass.OTPEncode(s), u)
+		return nil
+	}
+	pw, err := g.GenPW()
+	if err != nil {
+		return err
+	}
+	if ctx.Bool("quiet") {
+		fmt.Println(pw)
+		return nil
+	}
+	if g.Mode == dpass.ModeSSH {
+		fmt.Printf("Public: %s\n", pw)
+		return nil
+	}
+	fmt.Printf("PW: %s\n", pw)
+	return nil
+}
+
+// writeOut writes the password or key of the options to the out flag file.
+// Raw keys are written as bytes, SSH and X25519 keys as key files with the
+// public key in a .pub file, and anything else as text without a newline.
+func writeOut(ctx *cli.Context, g *dpass.GenOpts) error {
+	out := ctx.String("out")
+	var b []byte
+	var pub string
+	switch {
+	case g.Mode == dpass.ModeKey && g.KeyEncoding == dpass.KeyEncodingRaw:
+		k, err := g.GenKey()
+		if err != nil {
+			return err
+		}
+		b = k
+	case g.Mode == dpass.ModeSSH:
+		k, err := g.SSHPrivateKey()
+		if err != nil {
+			return err
+		}
+		b = k
+		if pub, err = g.GenPW(); err != nil {
+			return err
+		}
+	case g.Mode == dpass.ModeX25519:
+		priv, p, err := x25519Text(ctx, g)
+		if err != nil {
+			return err
+		}
+		b, pub = []byte(priv), p
+	default:
+		pw, err := g.GenPW()
+		if err != nil {
+			return err
+		}
+		b = []byte(pw)
+	}
+	if pub != "" {
+		if err := ioutil.WriteFile(out+".pub", []byte(pub+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	if err := writeSecret(out, b); err != nil {
+		return err
+	}
+	if !ctx.Bool("quiet") {
+		fmt.Printf("Wrote: %s\n", out)
+	}
+	return nil
+}
+
+// writeSecret writes b to a new file only readable by the user, and renames it
+// over path. Writing to path directly would keep the mode of an existing file.
+func writeSecret(path string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".dpass-tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// readPw prompts for the master password
+func readPw() ([]byte, error) {
+	fmt.Fprint(os.Stderr, "Enter Master Password: ")
+	//bytePassword, err := terminal.ReadPassword(int(syscall.Stdin))
+	bytePassword, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr, "")
+	return bytePassword, err
+}
+
+func Run(ctx *cli.Context) error {
+	g, err := optsFromCtx(ctx)
+	if err != nil {
+		return err
 	}
 
 	if ctx.Bool("json") {
@@ -153,23 +663,17 @@ func Run(ctx *cli.Context) error {
 		fmt.Printf("JSON: %s\n", j)
 	}
 
-	fmt.Fprint(os.Stderr, "Enter Master Password: ")
-	//bytePassword, err := terminal.ReadPassword(int(syscall.Stdin))
-	bytePassword, err := terminal.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Fprintln(os.Stderr, "")
+	bytePassword, err := readPw()
 	if err != nil {
 		return err
 	}
 
-	pw, err := dpass.GenPW(g, bytePassword)
-	if err != nil {
+	if err := g.HashPw(bytePassword); err != nil {
 		return err
 	}
-	if ctx.Bool("quiet") {
-		fmt.Println(pw)
-		return nil
+	if err := output(ctx, g); err != nil {
+		return err
 	}
-	fmt.Printf("PW: %s\n", pw)
 
 	if ctx.Bool("id") {
 		id, err := g.BlobIndex()
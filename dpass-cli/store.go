@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/clinta/dpass"
+	"github.com/urfave/cli"
+)
+
+var vaultFlag = cli.StringFlag{
+	Name:   "vault, V",
+	Usage:  "Directory to store encrypted options in",
+	EnvVar: "DPASS_VAULT",
+	Value:  filepath.Join(os.Getenv("HOME"), ".dpass", "vault"),
+}
+
+// selectFlags choose a saved entry for a domain
+var selectFlags = []cli.Flag{
+	vaultFlag,
+	kdfFlag,
+	argon2TimeFlag,
+	argon2MemoryFlag,
+	argon2ThreadsFlag,
+	cli.StringFlag{
+		Name:  "domain, d",
+		Usage: "Domain of the saved entry",
+	},
+	cli.StringFlag{
+		Name:  "username, u",
+		Usage: "Username of the saved entry",
+	},
+	cli.Uint64Flag{
+		Name:  "iteration, i",
+		Usage: "Iteration of the saved entry",
+	},
+	cli.StringFlag{
+		Name:  "identifier, id",
+		Usage: "Options ID, or a unique prefix of it, of the saved entry",
+	},
+}
+
+var storeCommands = []cli.Command{
+	{
+		Name:   "save",
+		Usage:  "Save the options to the vault",
+		Flags:  append([]cli.Flag{vaultFlag}, genFlags...),
+		Action: Save,
+	},
+	{
+		Name:  "list",
+		Usage: "List the saved entries for a domain",
+		Flags: []cli.Flag{
+			vaultFlag,
+			kdfFlag,
+			argon2TimeFlag,
+			argon2MemoryFlag,
+			argon2ThreadsFlag,
+			cli.StringFlag{
+				Name:  "domain, d",
+				Usage: "Domain to list entries for",
+			},
+		},
+		Action: List,
+	},
+	{
+		Name:  "get",
+		Usage: "Generate the password for a saved entry",
+		Flags: append(selectFlags, wordlistFlag, outFlag, wgInterfaceFlag, wgAddressFlag, wgListenPortFlag, cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "Print only the password to stdout",
+		}),
+		Action: Get,
+	},
+	{
+		Name:   "rm",
+		Usage:  "Remove a saved entry",
+		Flags:  selectFlags,
+		Action: Remove,
+	},
+}
+
+func Save(ctx *cli.Context) error {
+	g, err := optsFromCtx(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := dpass.NewDirStore(ctx.String("vault"))
+	if err != nil {
+		return err
+	}
+	pw, err := readPw()
+	if err != nil {
+		return err
+	}
+	if err := g.HashPw(pw); err != nil {
+		return err
+	}
+	idx, err := g.Save(s)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved: %s\n", idx)
+	if g.KDF != nil {
+		known := false
+		for _, k := range dpass.KnownKDFs() {
+			known = known || k == *g.KDF
+		}
+		if !known {
+			fmt.Println("The entry uses custom argon2id parameters, give list, get and rm the same --kdf and --argon2 flags to find it")
+		}
+	}
+	return nil
+}
+
+// loadDomain decrypts every saved entry for the domain flag
+func loadDomain(ctx *cli.Context) (dpass.Store, map[string]*dpass.GenOpts, error) {
+	dom := ctx.String("domain")
+	if dom == "" {
+		return nil, nil, fmt.Errorf("Domain required")
+	}
+	s, err := dpass.NewDirStore(ctx.String("vault"))
+	if err != nil {
+		return nil, nil, err
+	}
+	// Entries are saved under the hash of their own KDF, so look under each of
+	// the known KDFs, and the KDF of the kdf flags for entries saved with
+	// custom argon2id parameters.
+	ks := dpass.KnownKDFs()
+	k, err := kdfFromCtx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if k != nil {
+		known := false
+		for _, o := range ks {
+			known = known || o == *k
+		}
+		if !known {
+			ks = append(ks, *k)
+		}
+	}
+	pw, err := readPw()
+	if err != nil {
+		return nil, nil, err
+	}
+	es := map[string]*dpass.GenOpts{}
+	for _, k := range ks {
+		m, err := dpass.NewMasterKey(append([]byte{}, pw...), k)
+		if err != nil {
+			return nil, nil, err
+		}
+		kes, err := m.Load(s, dom)
+		if err != nil {
+			return nil, nil, err
+		}
+		for idx, e := range kes {
+			es[idx] = e
+		}
+	}
+	for i := range pw {
+		pw[i] = 0
+	}
+	if len(es) == 0 {
+		return nil, nil, fmt.Errorf("No saved entries for %s", dom)
+	}
+	return s, es, nil
+}
+
+func sortedIdxs(es map[string]*dpass.GenOpts) []string {
+	idxs := make([]string, 0, len(es))
+	for idx := range es {
+		idxs = append(idxs, idx)
+	}
+	sort.Strings(idxs)
+	return idxs
+}
+
+// policy describes the options of an entry which differ from the defaults
+func policy(g *dpass.GenOpts) string {
+	var p []string
+	p = append(p, fmt.Sprintf("characters=%d", g.Length))
+	minMax := func(name string, min uint64, max int) {
+		if min != 0 {
+			p = append(p, fmt.Sprintf("%s=%d", name, min))
+		}
+		if max != dpass.DefaultMax {
+			p = append(p, fmt.Sprintf("max-%s=%d", name, max))
+		}
+	}
+	minMax("numbers", g.Numbers, g.MaxNumbers)
+	minMax("uppers", g.Uppers, g.MaxUppers)
+	minMax("lowers", g.Lowers, g.MaxLowers)
+	minMax("symbols", g.Symbols, g.MaxSymbols)
+	if g.SymbolProfile != "" {
+		p = append(p, fmt.Sprintf("symbol-profile=%s", g.SymbolProfile))
+	} else if g.SymbolSet != dpass.DefaultSymbolSet {
+		p = append(p, fmt.Sprintf("symbol-set=%s", g.SymbolSet))
+	}
+	for _, c := range g.Classes {
+		p = append(p, fmt.Sprintf("class=%s:%d:%d:%s", c.Name, c.Min, c.Max, c.Chars))
+		if len(c.Blocks) > 0 {
+			p = append(p, fmt.Sprintf("blocks=%s", strings.Join(c.Blocks, ",")))
+		}
+	}
+	if g.ExcludeAmbiguous {
+		p = append(p, "exclude-ambiguous")
+	}
+	if g.Exclude != "" {
+		p = append(p, fmt.Sprintf("exclude=%s", g.Exclude))
+	}
+	if g.MaxLength != 0 {
+		p = append(p, fmt.Sprintf("max-length=%d", g.MaxLength))
+	}
+	if g.MinClasses != 0 {
+		p = append(p, fmt.Sprintf("min-classes=%d", g.MinClasses))
+	}
+	if g.MaxRepeat != 0 {
+		p = append(p, fmt.Sprintf("max-repeat=%d", g.MaxRepeat))
+	}
+	if g.MaxSequence != 0 {
+		p = append(p, fmt.Sprintf("max-sequence=%d", g.MaxSequence))
+	}
+	if g.Positions != "" {
+		p = append(p, fmt.Sprintf("positions=%s", g.Positions))
+	}
+	if g.PositionsEnd != "" {
+		p = append(p, fmt.Sprintf("positions-end=%s", g.PositionsEnd))
+	}
+	if g.Mode != dpass.ModeChars {
+		p = append(p, fmt.Sprintf("mode=%s", g.Mode))
+	}
+	if g.Mode == dpass.ModeWords {
+		p = append(p, fmt.Sprintf("words=%d word-sep=%q", g.Words, g.WordSep))
+		if g.WordCase != dpass.WordCaseLower {
+			p = append(p, fmt.Sprintf("word-case=%s", g.WordCase))
+		}
+		if g.WordAppend != dpass.WordAppendNone {
+			p = append(p, fmt.Sprintf("word-append=%s", g.WordAppend))
+		}
+		if g.WordlistHash != "" {
+			p = append(p, fmt.Sprintf("wordlist=%s", g.WordlistHash))
+		}
+	}
+	if g.PronounceTemplate != "" {
+		p = append(p, fmt.Sprintf("template=%s", g.PronounceTemplate))
+	}
+	if g.X25519Format != dpass.X25519FormatAge {
+		p = append(p, fmt.Sprintf("x25519-format=%s", g.X25519Format))
+	}
+	if g.Mode == dpass.ModeBIP39 {
+		p = append(p, fmt.Sprintf("mnemonic-bits=%d", g.MnemonicBits))
+	}
+	if g.Mode == dpass.ModeTOTP {
+		p = append(p, fmt.Sprintf("otp-period=%d otp-digits=%d", g.OTPPeriod, g.OTPDigits))
+		if g.OTPAlgorithm != dpass.OTPAlgorithmSHA1 {
+			p = append(p, fmt.Sprintf("otp-algorithm=%s", g.OTPAlgorithm))
+		}
+	}
+	if g.Mode == dpass.ModeKey {
+		p = append(p, fmt.Sprintf("key-bytes=%d", g.KeyBytes))
+		if g.KeyEncoding != dpass.KeyEncodingHex {
+			p = append(p, fmt.Sprintf("key-encoding=%s", g.KeyEncoding))
+		}
+	}
+	if g.PINBlockCommon {
+		p = append(p, "pin-block-common")
+	}
+	for _, b := range g.PINBlocklist {
+		p = append(p, fmt.Sprintf("pin-block=%s", b))
+	}
+	if g.KDF != nil {
+		p = append(p, fmt.Sprintf("kdf=%s", g.KDF))
+	}
+	if g.GenVersion != dpass.LatestGenVersion {
+		p = append(p, fmt.Sprintf("pw-version=%d", g.GenVersion))
+	}
+	return strings.Join(p, " ")
+}
+
+func List(ctx *cli.Context) error {
+	_, es, err := loadDomain(ctx)
+	if err != nil {
+		return err
+	}
+	for _, idx := range sortedIdxs(es) {
+		g := es[idx]
+		fmt.Printf("%s  %s  iteration=%d  %s\n", idx, g.Username, g.Iteration, policy(g))
+	}
+	return nil
+}
+
+// selectEntry loads the entries for a domain and returns the single one
+// matching the selection flags
+func selectEntry(ctx *cli.Context) (dpass.Store, string, *dpass.GenOpts, error) {
+	s, es, err := loadDomain(ctx)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var m []string
+	for _, idx := range sortedIdxs(es) {
+		g := es[idx]
+		if id := ctx.String("identifier"); id != "" && !strings.HasPrefix(idx, strings.ToUpper(id)) {
+			continue
+		}
+		if u := ctx.String("username"); u != "" && g.Username != u {
+			continue
+		}
+		if ctx.IsSet("iteration") && g.Iteration != ctx.Uint64("iteration") {
+			continue
+		}
+		m = append(m, idx)
+	}
+	switch len(m) {
+	case 0:
+		return nil, "", nil, fmt.Errorf("No saved entry matches")
+	case 1:
+		return s, m[0], es[m[0]], nil
+	}
+	return nil, "", nil, fmt.Errorf("%d saved entries match, select one with --username, --iteration or --identifier", len(m))
+}
+
+func Get(ctx *cli.Context) error {
+	_, _, g, err := selectEntry(ctx)
+	if err != nil {
+		return err
+	}
+	if err := setWordlist(ctx, g); err != nil {
+		return err
+	}
+	return output(ctx, g)
+}
+
+func Remove(ctx *cli.Context) error {
+	s, idx, _, err := selectEntry(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.Delete(idx); err != nil {
+		return err
+	}
+	fmt.Printf("Removed: %s\n", idx)
+	return nil
+}
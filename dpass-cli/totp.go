@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clinta/dpass"
+	"github.com/urfave/cli"
+)
+
+var totpCommand = cli.Command{
+	Name:  "totp",
+	Usage: "Print the current TOTP code of a saved totp entry",
+	Flags: append(selectFlags,
+		cli.Int64Flag{
+			Name:  "counter, c",
+			Usage: "Print the HOTP code for this counter instead of the TOTP code",
+			Value: -1,
+		},
+		cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "Print only the code to stdout",
+		},
+	),
+	Action: TOTP,
+}
+
+func TOTP(ctx *cli.Context) error {
+	_, _, g, err := selectEntry(ctx)
+	if err != nil {
+		return err
+	}
+	if g.Mode != dpass.ModeTOTP {
+		return fmt.Errorf("Entry is not a totp entry")
+	}
+	s, err := g.OTPSecret()
+	if err != nil {
+		return err
+	}
+
+	if c := ctx.Int64("counter"); c >= 0 {
+		code, err := g.HOTP(s, uint64(c))
+		if err != nil {
+			return err
+		}
+		if ctx.Bool("quiet") {
+			fmt.Println(code)
+			return nil
+		}
+		fmt.Printf("Code: %s\n", code)
+		return nil
+	}
+
+	code, left, err := g.TOTP(s, time.Now())
+	if err != nil {
+		return err
+	}
+	if ctx.Bool("quiet") {
+		fmt.Println(code)
+		return nil
+	}
+	fmt.Printf("Code: %s (%s left)\n", code, left)
+	return nil
+}
@@ -1,30 +1,113 @@
 package dpass
 
-import "fmt"
-
 const AppName = "dpass"
 
 // This is the version of the generator. It is encoded into the output blob for reverse compatibility if the generation algorithm has changed
-const LatestGenVersion = uint64(1)
+const LatestGenVersion = uint64(2)
 
 type GenOpts struct {
-	Domain     string   `json:"d"`
-	Username   string   `json:"u"`
-	Iteration  uint64   `json:"i"`
-	Length     uint64   `json:"c"`
-	GenVersion uint64   `json:"pwv"`
-	Numbers    uint64   `json:"n"`
-	MaxNumbers int      `json:"mn"`
-	Uppers     uint64   `json:"U"`
-	MaxUppers  int      `json:"mU"`
-	Lowers     uint64   `json:"l"`
-	MaxLowers  int      `json:"ml"`
-	Symbols    uint64   `json:"s"`
-	MaxSymbols int      `json:"ms"`
-	SymbolSet  string   `json:"ss"`
-	mpHash     [64]byte // The scrypt hash of the master password.
+	Domain     string     `json:"d"`
+	Username   string     `json:"u"`
+	Iteration  uint64     `json:"i"`
+	Length     uint64     `json:"c"`
+	GenVersion uint64     `json:"pwv"`
+	Numbers    uint64     `json:"n"`
+	MaxNumbers int        `json:"mn"`
+	Uppers     uint64     `json:"U"`
+	MaxUppers  int        `json:"mU"`
+	Lowers     uint64     `json:"l"`
+	MaxLowers  int        `json:"ml"`
+	Symbols    uint64     `json:"s"`
+	MaxSymbols int        `json:"ms"`
+	SymbolSet  string     `json:"ss"`
+	mk         *MasterKey // The hashed master password.
+
+	// KDF overrides the KDF profile of the GenVersion
+	KDF *KDFParams `json:"kdf,omitempty"`
+
+	// Classes are used by ModeChars in addition to the Number, Upper, Lower and
+	// Symbol sets. No two classes may share a character.
+	Classes []CharClass `json:"cc,omitempty"`
+
+	// SymbolProfile is the name of the profile the SymbolSet was set from, see
+	// SetSymbolProfile
+	SymbolProfile string `json:"sp,omitempty"`
+
+	// ExcludeAmbiguous removes the AmbiguousChars from every class, and Exclude
+	// removes any other characters, even those of a SymbolProfile.
+	ExcludeAmbiguous bool   `json:"xa,omitempty"`
+	Exclude          string `json:"x,omitempty"`
+
+	// MaxLength makes Length the minimum of a range of lengths. The length of
+	// each password is chosen at random in the range. Used by ModeChars.
+	MaxLength uint64 `json:"cx,omitempty"`
+
+	// MinClasses is how many of the classes must be used at least once. Classes
+	// with a minimum count toward it, the others are chosen at random.
+	MinClasses uint64 `json:"mc,omitempty"`
+
+	// MaxRepeat limits how many identical characters may be in a row, and
+	// MaxSequence how long a run like abc or 987 may be. 0 means no limit.
+	MaxRepeat   uint64 `json:"mr,omitempty"`
+	MaxSequence uint64 `json:"mq,omitempty"`
+
+	// Positions restricts the class of each character from the start of the
+	// password, and PositionsEnd of each character up to the end, see the Pos
+	// constants. For example Positions "a" requires a letter first, and
+	// PositionsEnd "A" forbids a symbol last.
+	Positions    string `json:"ps,omitempty"`
+	PositionsEnd string `json:"pe,omitempty"`
+
+	// Mode selects the kind of password GenPW generates, ModeChars if empty
+	Mode string `json:"m,omitempty"`
+
+	// Passphrase options, used by ModeWords
+	Words        uint64   `json:"w,omitempty"`  // Number of words
+	WordSep      string   `json:"ws,omitempty"` // Separator between words
+	WordCase     string   `json:"wc,omitempty"` // One of the WordCase constants
+	WordAppend   string   `json:"wa,omitempty"` // One of the WordAppend constants
+	WordlistHash string   `json:"wl,omitempty"` // Hash of a custom word list, see SetWordlist
+	wordlist     []string // The custom word list
+
+	// PIN options, used by ModePIN
+	PINBlockCommon bool     `json:"pc,omitempty"` // Skip commonly used PINs
+	PINBlocklist   []string `json:"pb,omitempty"` // Skip these PINs
+
+	// Key options, used by ModeKey
+	KeyBytes    uint64 `json:"kb,omitempty"` // Number of bytes of the key
+	KeyEncoding string `json:"ke,omitempty"` // One of the KeyEncoding constants
+
+	// X25519Format is one of the X25519Format constants, used by ModeX25519
+	X25519Format string `json:"xf,omitempty"`
+
+	// OTP options, used by ModeTOTP. Zero values use the defaults.
+	OTPPeriod    uint64 `json:"tp,omitempty"` // Seconds each code is valid for
+	OTPDigits    uint64 `json:"td,omitempty"` // Digits of each code
+	OTPAlgorithm string `json:"ta,omitempty"` // One of the OTPAlgorithm constants
+
+	// MnemonicBits is the entropy of the mnemonic of ModeBIP39
+	MnemonicBits uint64 `json:"mb,omitempty"`
+
+	// PronounceTemplate is used by ModePronounceable, see the Tmpl constants.
+	// If empty, consonant-vowel-consonant syllables are repeated to Length.
+	PronounceTemplate string `json:"pt,omitempty"`
+}
+
+// CharClass is a user defined class of characters.
+// Every character must be a single NFC normalized grapheme cluster, so the
+// Length of the options always counts grapheme clusters.
+type CharClass struct {
+	Name   string   `json:"n"`
+	Chars  string   `json:"c"`
+	Blocks []string `json:"b,omitempty"` // Names of unicode blocks to add to Chars, such as greek or emoji
+	Min    uint64   `json:"min"`
+	Max    int      `json:"max"` // -1 means no max
 }
 
+// AmbiguousChars are easily confused with each other when read from a screen
+// or printout
+const AmbiguousChars = "0Oo1lI|;:`'\""
+
 const (
 	DefaultMax       = -1
 	DefaultLength    = 24
@@ -46,6 +129,20 @@ func NewGenOpts(u, d string) *GenOpts {
 	}
 }
 
+// Modes select the kind of password GenPW generates
+const (
+	ModeChars = ""      // Characters from the Number, Upper, Lower and Symbol sets
+	ModeWords = "words" // A passphrase of words from a word list
+	ModePIN   = "pin"   // A numeric PIN of Length digits
+
+	ModePronounceable = "pronounceable" // Syllables from a PronounceTemplate
+	ModeKey           = "key"           // KeyBytes of raw key material, see GenKey
+	ModeSSH           = "ssh"           // The authorized_keys line of an ed25519 key, see SSHKey
+	ModeX25519        = "x25519"        // The private key of an X25519 keypair, see X25519
+	ModeTOTP          = "totp"          // The base32 shared secret of TOTP codes, see OTPSecret
+	ModeBIP39         = "bip39"         // A BIP 39 mnemonic of MnemonicBits of entropy
+)
+
 const (
 	Number = iota
 	Upper
@@ -55,31 +152,47 @@ const (
 
 const maxCharset = Symbol
 
-type charSet struct {
-	chars chars
-	min   uint64
-	max   uint64
-	cur   uint64
+// A generator creates a password from the options and a hashStream seeded from them.
+// A generator must never change once it has been released, instead a new
+// GenVersion should be registered.
+type generator func(g *GenOpts, h *hashStream) (string, error)
+
+// generators maps each GenVersion to the generator which implements each Mode
+var generators = map[uint64]map[string]generator{
+	1: {
+		ModeChars: genV1,
+	},
+	2: {
+		ModeChars: genV2,
+		ModeWords: genWordsV2,
+		ModePIN:   genPINV2,
+		ModeKey:   genKeyV2,
+		ModeSSH:   genSSHV2,
+		ModeTOTP:  genTOTPV2,
+		ModeBIP39: genBIP39V2,
+
+		ModePronounceable: genPronounceV2,
+		ModeX25519:        genX25519V2,
+	},
 }
 
-func (c *charSet) setMax(max int, length uint64) {
-	if max < 0 || max > int(length) {
-		c.max = length
-	}
-	c.max = uint64(max)
+// A deriver reads the raw secret of a key Mode from a hashStream seeded from
+// the options. The generator of the Mode encodes the same secret, so like the
+// generators a deriver must never change once it has been released.
+type deriver func(g *GenOpts, h *hashStream) ([]byte, error)
+
+// derivers maps each GenVersion to the deriver of the raw secret of each key Mode
+var derivers = map[uint64]map[string]deriver{
+	2: {
+		ModeKey:    keyV2,
+		ModeSSH:    sshKeyV2,
+		ModeTOTP:   otpSecretV2,
+		ModeX25519: x25519V2,
+	},
 }
 
 type chars []rune
 
-func (c *charSet) populate(minRune, maxRune rune) {
-	c.chars = make(chars, maxRune-minRune+1)
-	i := 0
-	for j := minRune; j <= maxRune; j++ {
-		c.chars[i] = j
-		i++
-	}
-}
-
 func (c chars) index(r rune) int {
 	for i, ru := range c {
 		if ru == r {
@@ -89,151 +202,32 @@ func (c chars) index(r rune) int {
 	return -1
 }
 
-// this configures and validates the character sets for generating a password
-// It is called automatically when generating a password, but can be called
-// manually to validate character set options if desired
-func (g *GenOpts) getChars() (globalChars chars, charSets []*charSet, err error) {
-	charSets = make([]*charSet, maxCharset+1)
-
-	charSets[Number] = &charSet{min: g.Numbers}
-	charSets[Number].setMax(g.MaxNumbers, g.Length)
-	charSets[Number].populate('0', '9')
-
-	charSets[Upper] = &charSet{min: g.Uppers}
-	charSets[Upper].setMax(g.MaxUppers, g.Length)
-	charSets[Upper].populate('A', 'Z')
-
-	charSets[Lower] = &charSet{min: g.Lowers}
-	charSets[Lower].setMax(g.MaxLowers, g.Length)
-	charSets[Lower].populate('a', 'z')
-
-	charSets[Symbol] = &charSet{min: g.Symbols}
-	charSets[Symbol].setMax(g.MaxSymbols, g.Length)
-	for _, r := range g.SymbolSet {
-		if charSets[Symbol].chars.index(r) == -1 {
-			charSets[Symbol].chars = append(charSets[Symbol].chars, r)
-		}
-	}
-
-	tm := uint64(0) // total max
-	for _, c := range charSets {
-		if c.min > c.max {
-			err = fmt.Errorf("Character set min > max")
-			return
-		}
-		tm += c.min
-		if tm > g.Length {
-			err = fmt.Errorf("Minimum character requirements are greater than the length")
-			return
-		}
-		if c.max == 0 {
+func (c chars) remove(rs ...rune) chars {
+	for _, r := range rs {
+		i := c.index(r)
+		if i == -1 {
 			continue
 		}
-		globalChars = append(globalChars, c.chars...)
-	}
-	return
-}
-
-// GenPW will perform all the steps required and return a deterministic
-// password based on the supplied options and master password
-func GenPW(g *GenOpts, pw []byte) (string, error) {
-	if err := g.HashPw(pw); err != nil {
-		return "", err
+		c = append(c[:i], c[i+1:]...)
 	}
-	return g.GenPW()
+	return c
 }
 
 // GenPW will generate a deterministic password based on the initialized options
 // and hashed master password.
 func (g *GenOpts) GenPW() (string, error) {
-	if g.mpHash == [64]byte{} {
-		return "", fmt.Errorf("No password has been hashed yet.")
-	}
-	globalChars, charSets, err := g.getChars()
-	if err != nil {
-		return "", err
-	}
-	h, err := g.makeHashStream()
+	m, err := g.masterKey()
 	if err != nil {
 		return "", err
 	}
-
-	pwo := make([]uint64, g.Length) // the order to fill characters
-	pwr := make([]uint64, g.Length) // remainding positions to be allocated
-	for i := uint64(0); i < g.Length; i++ {
-		pwr[i] = i
-	}
-
-	// Get the deterministic, random order which the pw will be filled
-	// This is important so that character sets with a maximum limit are
-	// not biased toward the beginning of the password.
-	for i := uint64(0); i < g.Length; i++ {
-		j := h.nextMax(uint64(len(pwr)))
-		pwo[i] = pwr[j]
-		pwr = append(pwr[:j], pwr[j+1:]...)
-	}
-
-	// time to fill the password
-	pw := make([]rune, g.Length)
-	for _, p := range pwo {
-		if len(globalChars) == 0 {
-			return "", fmt.Errorf("Unable to satisfy requirements")
-		}
-		j := h.nextMax(uint64(len(globalChars)))
-		r := globalChars[j]
-		pw[p] = r
-		globalChars, err = globalChars.updateChars(charSets, r)
-		if err != nil {
-			return "", err
-		}
-	}
-
-	// Replace characters until it meets the minimum requirements.
-	// This can be done in the same "random" order that the password was filled.
-	i := 0
-	for _, c := range charSets {
-		for c.cur < c.min {
-			p := pwo[i]
-			j := h.nextMax(uint64(len(c.chars)))
-			r := c.chars[j]
-			pw[p] = r
-			globalChars, err = globalChars.updateChars(charSets, r)
-			if err != nil {
-				return "", err
-			}
-			i++
-		}
-	}
-
-	return string(pw), nil
-}
-
-// updateChars increments all the appropriate character class counters
-// and removes character sets from the global pool if they have reached
-// their max.
-func (cs chars) updateChars(charSets []*charSet, r rune) (chars, error) {
-	for _, c := range charSets {
-		if c.chars.index(r) == -1 {
-			continue
-		}
-		c.cur++
-		if c.cur == c.max {
-			cs = cs.remove(c.chars...)
-		}
-		if c.cur > c.max {
-			return cs, fmt.Errorf("Unable to satisfy maximum requirements")
-		}
-	}
-	return cs, nil
+	return m.GenPW(g)
 }
 
-func (c chars) remove(rs ...rune) chars {
-	for _, r := range rs {
-		i := c.index(r)
-		if i == -1 {
-			continue
-		}
-		c = append(c[:i], c[i+1:]...)
+// GenPW will perform all the steps required and return a deterministic
+// password based on the supplied options and master password
+func GenPW(g *GenOpts, pw []byte) (string, error) {
+	if err := g.HashPw(pw); err != nil {
+		return "", err
 	}
-	return c
+	return g.GenPW()
 }
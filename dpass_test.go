@@ -22,13 +22,39 @@ func pwTest(t *testing.T, g *GenOpts, mpw, pw string) {
 }
 
 func TestDefaults(t *testing.T) {
-	epw := "V346Cw%.^2UuY!G;+%@eG~2Y"
+	epw := "xA#oEFMi9u@+1CLW-uF#mW5V"
 	pwTest(t, newG1Opts(), testPw, epw)
 }
 
 func Test50Char(t *testing.T) {
-	epw := ".u=z5S*jTQyWm1)Fec*Hyr(S&BkT~sE8CHx5f?C8_R)9&qQdcn"
+	epw := "@#uVHX+-N39AhM^W4CukHDeFXWix#ePb1BF.koE6JjLrGx45#m"
 	g := newG1Opts()
 	g.Length = 50
 	pwTest(t, g, testPw, epw)
 }
+
+func TestUnknownGenVersion(t *testing.T) {
+	g := newG1Opts()
+	g.GenVersion = 0
+	_, err := GenPW(g, []byte(testPw))
+	assert.Error(t, err)
+	g.GenVersion = LatestGenVersion + 1
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(t, err)
+}
+
+// GenVersion 1 is frozen, these must never change
+func TestV1Defaults(t *testing.T) {
+	epw := "GFys%8=HuPe=J_ABRGu+6Y$S"
+	g := newG1Opts()
+	g.GenVersion = 1
+	pwTest(t, g, testPw, epw)
+}
+
+func TestV1_50Char(t *testing.T) {
+	epw := "!V@GYqg0iV9wRE!^z#k%_a34%Tz~Q?UBF.F3SunwcX4P1s0HSV"
+	g := newG1Opts()
+	g.GenVersion = 1
+	g.Length = 50
+	pwTest(t, g, testPw, epw)
+}
@@ -8,7 +8,6 @@ import (
 	"encoding/base32"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 
 	"golang.org/x/crypto/nacl/secretbox"
 )
@@ -23,15 +22,11 @@ func FromJSON(d []byte) (*GenOpts, error) {
 	return g, json.Unmarshal(d, g)
 }
 
-// Returns the sha512_256 of the scrypt hash and the domain name, used both for
+// Returns the sha512_256 of the master key and the domain name, used both for
 // generating the blobIndex and the blob encryption key
-func (g *GenOpts) blobKey() ([32]byte, error) {
-	if g.mpHash == [64]byte{} {
-		return [32]byte{}, fmt.Errorf("No password has been hashed yet.")
-	}
-	seedSrc := append(g.mpHash[:], []byte(g.Domain)...)
-	dh := sha512.Sum512_256(seedSrc)
-	return dh, nil
+func (m *MasterKey) blobKey(dom string) [32]byte {
+	seedSrc := append(m.hash[:], []byte(dom)...)
+	return sha512.Sum512_256(seedSrc)
 }
 
 func (g *GenOpts) jsonKey() (string, error) {
@@ -39,56 +34,84 @@ func (g *GenOpts) jsonKey() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return jsonKeyOf(j), nil
+}
+
+// jsonKeyOf returns the BlobIndex suffix for the json encoded options j
+func jsonKeyOf(j []byte) string {
 	jh := sha512.Sum512_256(j)
-	return base32.HexEncoding.EncodeToString(jh[:5]), nil
+	return base32.HexEncoding.EncodeToString(jh[:5])
 }
 
-func (g *GenOpts) blobIndexPrefix() (string, error) {
-	dh, err := g.blobKey()
-	if err != nil {
-		return "", err
-	}
+// BlobIndexPrefix returns the blob index prefix which can be used by an
+// interface to look up all blobs for a domain saved with this master key.
+func (m *MasterKey) BlobIndexPrefix(dom string) string {
+	dh := m.blobKey(dom)
 	dh = sha512.Sum512_256(dh[:])
 	// base32 extended works on case insensitive filesystems
-	return base32.HexEncoding.EncodeToString(dh[:10]), nil
+	return base32.HexEncoding.EncodeToString(dh[:10])
 }
 
 // Given a domain and password, return the blob index prefix which
 // can be used by an interface to look up all blobs for that domain
-func BlobIndexPrefix(dom string, pw []byte) (string, error) {
-	g := NewGenOpts("", dom)
-	if err := g.HashPw(pw); err != nil {
+// saved by options using the KDF k.
+func BlobIndexPrefix(dom string, pw []byte, k KDFParams) (string, error) {
+	m, err := NewMasterKey(pw, k)
+	if err != nil {
 		return "", err
 	}
-	return g.blobIndexPrefix()
+	return m.BlobIndexPrefix(dom), nil
 }
 
 // BlobIndex returns the index string which can identify an encrypted
-// options blob. The first 22 characters are the base32 double sha512_128 sum of the
-// domain name and mphash.
-// The remaining 6 characters are a hash of the json encoded options to
-// uniquely identify this entry for the domain.
-// This makes searching for entries for a given domain possible before decryption.
+// options blob. See MasterKey.BlobIndex.
 func (g *GenOpts) BlobIndex() (string, error) {
-	bp, err := g.blobIndexPrefix()
+	m, err := g.masterKey()
 	if err != nil {
 		return "", err
 	}
+	return m.BlobIndex(g)
+}
+
+// BlobIndex returns the index string which can identify an encrypted
+// options blob. The first 16 characters are the base32 double sha512_256 sum of the
+// domain name and master key.
+// The remaining 8 characters are a hash of the json encoded options to
+// uniquely identify this entry for the domain.
+// This makes searching for entries for a given domain possible before decryption.
+func (m *MasterKey) BlobIndex(g *GenOpts) (string, error) {
+	if err := m.check(g); err != nil {
+		return "", err
+	}
 	jk, err := g.jsonKey()
 	if err != nil {
 		return "", err
 	}
-	s := bp + jk
+	s := m.BlobIndexPrefix(g.Domain) + jk
 	return s, nil
 }
 
-// Blob returns a base32 encoded encrypted blob of the json encoded options.
+// Blob returns a base64 encoded encrypted blob of the json encoded options.
+// See MasterKey.Blob.
+func (g *GenOpts) Blob() (string, error) {
+	m, err := g.masterKey()
+	if err != nil {
+		return "", err
+	}
+	return m.Blob(g)
+}
+
+// Blob returns a base64 encoded encrypted blob of the json encoded options.
 // First the json is compressed, then encrypted using the sha512_256 sum of the
-// scrypt master password hash + the domain name. This makes the encryption key
+// master key + the domain name. This makes the encryption key
 // unique for each domain, but allows decrypting all entries for a single domain
 // with a single key so that an interface can allow choosing from entries in a
 // domain.
-func (g *GenOpts) Blob() (string, error) {
+func (m *MasterKey) Blob(g *GenOpts) (string, error) {
+	if err := m.check(g); err != nil {
+		return "", err
+	}
+
 	// Get the json
 	j, err := g.JSON()
 	if err != nil {
@@ -107,10 +130,7 @@ func (g *GenOpts) Blob() (string, error) {
 	rand.Read(n[:])
 
 	// Get the key
-	dh, err := g.blobKey()
-	if err != nil {
-		return "", err
-	}
+	dh := m.blobKey(g.Domain)
 
 	// Seal it with the nonce prepended
 	out := make([]byte, len(n))
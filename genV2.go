@@ -0,0 +1,291 @@
+package dpass
+
+import "fmt"
+
+// class is a character class used by genV2
+type class struct {
+	name  string
+	chars chars
+	min   uint64
+	max   uint64
+	cur   uint64
+}
+
+// newClass returns a class with a max of -1, or anything greater than length,
+// clamped to length
+func newClass(name string, cs chars, min uint64, max int, length uint64) *class {
+	c := &class{name: name, chars: cs, min: min, max: length}
+	if max >= 0 && uint64(max) < length {
+		c.max = uint64(max)
+	}
+	if len(cs) == 0 {
+		c.max = 0
+	}
+	return c
+}
+
+func charRange(minRune, maxRune rune) chars {
+	c := make(chars, 0, maxRune-minRune+1)
+	for r := minRune; r <= maxRune; r++ {
+		c = append(c, r)
+	}
+	return c
+}
+
+// uniq returns the characters of s without duplicates
+func uniq(s string) chars {
+	var c chars
+	for _, r := range s {
+		if c.index(r) == -1 {
+			c = append(c, r)
+		}
+	}
+	return c
+}
+
+// excluded returns the characters which may not be used in a password
+func (g *GenOpts) excluded() chars {
+	x := chars(g.Exclude)
+	if g.ExcludeAmbiguous {
+		x = append(x, chars(AmbiguousChars)...)
+	}
+	return x
+}
+
+// onlyChars returns an error if the options set a feature of ModeChars, which
+// the other modes of generation version 2 would silently ignore. Modes which
+// apply the exclusions themselves pass exclude to allow them.
+func (g *GenOpts) onlyChars(exclude bool) error {
+	if len(g.Classes) > 0 {
+		return fmt.Errorf("Character classes are not supported by mode %s", g.Mode)
+	}
+	if !exclude && len(g.excluded()) > 0 {
+		return fmt.Errorf("Excluding characters is not supported by mode %s", g.Mode)
+	}
+	if g.limitsRuns() {
+		return fmt.Errorf("Repeat and sequence limits are not supported by mode %s", g.Mode)
+	}
+	if g.MaxLength != 0 {
+		return fmt.Errorf("Length ranges are not supported by mode %s", g.Mode)
+	}
+	if g.MinClasses != 0 {
+		return fmt.Errorf("Minimum classes are not supported by mode %s", g.Mode)
+	}
+	if g.positional() {
+		return fmt.Errorf("Position templates are not supported by mode %s", g.Mode)
+	}
+	return nil
+}
+
+// classes configures and validates the character classes for genV2.
+// Any options accepted by classes will generate a password.
+func (g *GenOpts) classes(length uint64) ([]*class, error) {
+	sym, err := setChars("Symbol set", g.SymbolSet)
+	if err != nil {
+		return nil, err
+	}
+	cs := []*class{
+		newClass("numbers", charRange('0', '9'), g.Numbers, g.MaxNumbers, length),
+		newClass("uppers", charRange('A', 'Z'), g.Uppers, g.MaxUppers, length),
+		newClass("lowers", charRange('a', 'z'), g.Lowers, g.MaxLowers, length),
+		newClass("symbols", sym, g.Symbols, g.MaxSymbols, length),
+	}
+	for _, cc := range g.Classes {
+		if cc.Name == "" {
+			return nil, fmt.Errorf("Character class name required")
+		}
+		ccs, err := cc.classChars(g.GenVersion)
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, newClass(cc.Name, ccs, cc.Min, cc.Max, length))
+	}
+	if x := g.excluded(); len(x) > 0 {
+		for _, c := range cs {
+			c.chars = c.chars.remove(x...)
+			if len(c.chars) == 0 {
+				c.max = 0
+			}
+		}
+	}
+
+	// A character in two classes would count toward both of them, so classes
+	// may not overlap. Disabled classes are never drawn from, so they may.
+	for i, c := range cs {
+		for _, o := range cs[:i] {
+			if o.name == c.name {
+				return nil, fmt.Errorf("Character class %s is defined twice", c.name)
+			}
+			if c.max == 0 || o.max == 0 {
+				continue
+			}
+			for _, r := range c.chars {
+				if o.chars.index(r) != -1 {
+					return nil, fmt.Errorf("Character classes %s and %s both contain %q", o.name, c.name, r)
+				}
+			}
+		}
+	}
+
+	tmin, tmax := uint64(0), uint64(0)
+	for _, c := range cs {
+		if c.min > c.max {
+			return nil, fmt.Errorf("Character class %s min > max", c.name)
+		}
+		tmin += c.min
+		tmax += c.max
+	}
+	if tmin > length {
+		return nil, fmt.Errorf("Minimum character requirements are greater than the length")
+	}
+	if tmax < length {
+		return nil, fmt.Errorf("Maximum character limits are less than the length")
+	}
+
+	required, usable := uint64(0), uint64(0)
+	for _, c := range cs {
+		if c.min > 0 {
+			required++
+		}
+		if c.max > 0 {
+			usable++
+		}
+	}
+	if g.MinClasses > usable {
+		return nil, fmt.Errorf("Only %d character classes can be used, %d are required", usable, g.MinClasses)
+	}
+	if g.MinClasses > required && tmin+g.MinClasses-required > length {
+		return nil, fmt.Errorf("Minimum character requirements are greater than the length")
+	}
+	return cs, nil
+}
+
+// requireClasses raises the min of randomly chosen classes to 1, until at least
+// MinClasses of the classes are required
+func (g *GenOpts) requireClasses(h *hashStream, cs []*class) {
+	var optional []*class
+	n := uint64(0)
+	for _, c := range cs {
+		if c.min > 0 {
+			n++
+		} else if c.max > 0 {
+			optional = append(optional, c)
+		}
+	}
+	for ; n < g.MinClasses; n++ {
+		j := h.uniform(uint64(len(optional)))
+		optional[j].min = 1
+		optional = append(optional[:j], optional[j+1:]...)
+	}
+}
+
+// validateV2 returns the classes and the classes allowed in each position
+// for a password of length, or an error if no password can be generated.
+// The run limits are only checked by fillRuns.
+func (g *GenOpts) validateV2(length uint64) ([]*class, [][]bool, error) {
+	cs, err := g.classes(length)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := g.allowed(cs, length)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, ok := maxClasses(cs, a, make([]rune, length))
+	if !ok {
+		return nil, nil, fmt.Errorf("Position template can not be filled within the class limits")
+	}
+	if n < g.MinClasses {
+		return nil, nil, fmt.Errorf("Position template allows only %d character classes, %d are required", n, g.MinClasses)
+	}
+	return cs, a, nil
+}
+
+// genV2 fills the minimum of each class into random positions, then fills the
+// remaining positions from every class which has not reached its max.
+// With a MaxLength, the length is drawn from the hashStream first.
+// With a position template, positions are filled by fillPositions instead, and
+// with MaxRepeat or MaxSequence by fillRuns. Both of them use at least
+// MinClasses classes by only drawing characters which leave it possible.
+// All random numbers are drawn without modulo bias.
+func genV2(g *GenOpts, h *hashStream) (string, error) {
+	if g.Length == 0 {
+		return "", fmt.Errorf("Length must be greater than 0")
+	}
+	length := g.Length
+	if g.MaxLength != 0 {
+		if g.MaxLength < g.Length {
+			return "", fmt.Errorf("Max length is less than the length")
+		}
+		// Position templates can make a length in the middle of the range
+		// impossible, so every length is checked.
+		for l := g.Length; l <= g.MaxLength; l++ {
+			if _, _, err := g.validateV2(l); err != nil {
+				return "", err
+			}
+		}
+		length += h.uniform(g.MaxLength - g.Length + 1)
+	}
+	cs, a, err := g.validateV2(length)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case g.limitsRuns():
+		// Searching for a password within the run limits is slow, so unlike
+		// the checks above it is only done for the drawn length.
+		pw, ok := g.fillRuns(h, cs, a, length)
+		if !ok {
+			return "", fmt.Errorf("No password of length %d can satisfy the repeat and sequence limits", length)
+		}
+		return string(pw), nil
+	case g.positional():
+		return string(g.fillPositions(h, cs, a, length)), nil
+	}
+	// Without positions, the classes required by MinClasses can be chosen
+	// before filling
+	g.requireClasses(h, cs)
+	return string(fillV2(h, cs, length)), nil
+}
+
+// fillV2 generates a password of length from classes validated by validateV2
+func fillV2(h *hashStream, cs []*class, length uint64) []rune {
+	pw := make([]rune, length)
+	free := make([]uint64, length) // positions not yet filled
+	for i := range free {
+		free[i] = uint64(i)
+	}
+	// fill places r in a random free position
+	fill := func(c *class, r rune) {
+		j := h.uniform(uint64(len(free)))
+		pw[free[j]] = r
+		free = append(free[:j], free[j+1:]...)
+		c.cur++
+	}
+
+	for _, c := range cs {
+		for c.cur < c.min {
+			fill(c, c.chars[h.uniform(uint64(len(c.chars)))])
+		}
+	}
+
+	// Classes are validated to not overlap, and the sum of their max to be at
+	// least the length, so the pool can not run empty.
+	for len(free) > 0 {
+		var pool chars
+		var owner []*class
+		for _, c := range cs {
+			if c.cur >= c.max {
+				continue
+			}
+			pool = append(pool, c.chars...)
+			for range c.chars {
+				owner = append(owner, c)
+			}
+		}
+		j := h.uniform(uint64(len(pool)))
+		fill(owner[j], pool[j])
+	}
+
+	return pw
+}
@@ -0,0 +1,570 @@
+package dpass
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func countIn(pw string, cs string) int {
+	n := 0
+	for _, r := range pw {
+		if strings.ContainsRune(cs, r) {
+			n++
+		}
+	}
+	return n
+}
+
+// Every combination of options accepted by classes must produce a password
+// which meets the requirements.
+func TestV2Satisfiable(t *testing.T) {
+	assert := assert.New(t)
+	h := newG1Opts()
+	assert.NoError(h.HashPw([]byte(testPw)))
+
+	mins := []uint64{0, 1, 3}
+	maxs := []int{-1, 0, 2, 5, 30}
+	for _, l := range []uint64{1, 4, 8} {
+		for _, n := range mins {
+			for _, mn := range maxs {
+				for _, s := range mins {
+					for _, ms := range maxs {
+						for _, mU := range maxs {
+							g := newG1Opts()
+							g.mk = h.mk
+							g.Length = l
+							g.Numbers, g.MaxNumbers = n, mn
+							g.Symbols, g.MaxSymbols = s, ms
+							g.Uppers, g.MaxUppers = 1, mU
+							g.MaxLowers = 0
+							if _, err := g.classes(g.Length); err != nil {
+								continue
+							}
+							pw, err := g.GenPW()
+							if !assert.NoError(err, "%+v", g) {
+								continue
+							}
+							assert.Equal(int(l), len(pw))
+							nc := countIn(pw, "0123456789")
+							sc := countIn(pw, g.SymbolSet)
+							uc := countIn(pw, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+							assert.True(nc >= int(n) && (mn < 0 || nc <= mn), "%s %+v", pw, g)
+							assert.True(sc >= int(s) && (ms < 0 || sc <= ms), "%s %+v", pw, g)
+							assert.True(uc >= 1 && (mU < 0 || uc <= mU), "%s %+v", pw, g)
+							assert.Equal(int(l), nc+sc+uc, "%s %+v", pw, g)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestV2Invalid(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	g.Length = 4
+	g.MaxNumbers, g.MaxUppers, g.MaxLowers, g.MaxSymbols = 1, 1, 1, 0
+	_, err := GenPW(g, []byte(testPw))
+	assert.Error(err, "max less than length")
+
+	g = newG1Opts()
+	g.SymbolSet = "!a"
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "symbol set overlaps lowers")
+
+	g = newG1Opts()
+	g.Numbers, g.MaxNumbers = 2, 1
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "min > max")
+}
+
+func TestUniform(t *testing.T) {
+	h := &hashStream{}
+	var counts [3]int
+	for i := 0; i < 3000; i++ {
+		counts[h.uniform(3)]++
+	}
+	for _, c := range counts {
+		assert.InDelta(t, 1000, c, 150)
+	}
+	assert.Equal(t, uint64(0), h.uniform(0))
+}
+
+func TestV2CustomClasses(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Length = 12
+	g.MaxSymbols = 0
+	g.Classes = []CharClass{
+		{Name: "bang", Chars: "!@#", Min: 1, Max: -1},
+		{Name: "brackets", Chars: "()[]", Min: 1, Max: 2},
+	}
+	for i := uint64(0); i < 20; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.True(countIn(pw, "!@#") >= 1, pw)
+		b := countIn(pw, "()[]")
+		assert.True(b >= 1 && b <= 2, pw)
+		assert.Equal(0, countIn(pw, "~$%^*_+-=;,./?"), pw)
+	}
+
+	// The same classes round trip through json
+	j, err := g.JSON()
+	assert.NoError(err)
+	o, err := FromJSON(j)
+	assert.NoError(err)
+	assert.Equal(g.Classes, o.Classes)
+
+	g.MaxSymbols = DefaultMax
+	_, err = m.GenPW(g)
+	assert.EqualError(err, `Character classes symbols and bang both contain '!'`)
+
+	g.MaxSymbols = 0
+	g.Classes = append(g.Classes, CharClass{Name: "bang", Chars: "%", Max: -1})
+	_, err = m.GenPW(g)
+	assert.Error(err, "duplicate name")
+
+	g.GenVersion = 1
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "v1 does not support classes")
+}
+
+func TestV2Exclude(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Length = 40
+	g.ExcludeAmbiguous = true
+	g.Exclude = "xyz"
+	colons := []CharClass{{Name: "colons", Chars: ":", Max: -1}}
+	g.Classes = colons
+	for i := uint64(0); i < 20; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.Equal(0, countIn(pw, AmbiguousChars+"xyz"), pw)
+
+		g.Mode = ModePronounceable
+		g.Uppers, g.Numbers = 10, 3
+		g.Classes = nil
+		pw, err = m.GenPW(g)
+		assert.NoError(err)
+		assert.Equal(0, countIn(pw, AmbiguousChars+"xyz"), pw)
+		g.Mode = ModeChars
+		g.Uppers, g.Numbers = 0, 0
+		g.Classes = colons
+	}
+
+	// A class with every character excluded can not meet its minimum
+	g.Classes[0].Min = 1
+	_, err = m.GenPW(g)
+	assert.Error(err)
+
+	g = newG1Opts()
+	g.GenVersion = 1
+	g.ExcludeAmbiguous = true
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "v1 does not support exclusions")
+}
+
+func TestRuns(t *testing.T) {
+	assert := assert.New(t)
+	for s, want := range map[string][2]uint64{
+		"":        {0, 0},
+		"a":       {1, 1},
+		"aab":     {2, 2},
+		"abc":     {1, 3},
+		"cba":     {1, 3},
+		"abcba":   {1, 3},
+		"789x":    {1, 3},
+		"9:;":     {1, 1},
+		"yzAB":    {1, 2},
+		"x111":    {3, 1},
+		"Za1aaa2": {3, 1},
+	} {
+		r, q := runs([]rune(s))
+		assert.Equal(want, [2]uint64{r, q}, s)
+	}
+}
+
+func TestV2Runs(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.GenVersion = 2
+	g.Length = 4
+	g.SymbolSet = ""
+	g.Uppers, g.Lowers, g.Symbols = 0, 0, 0
+	g.MaxUppers, g.MaxLowers, g.MaxSymbols = 0, 0, 0
+	g.Numbers = 0
+	g.MaxRepeat = 1
+	g.MaxSequence = 1
+	// Long passwords of only digits are always possible
+	for _, l := range []uint64{4, 24, 64} {
+		g.Length = l
+		for i := uint64(0); i < 20; i++ {
+			g.Iteration = i
+			pw, err := m.GenPW(g)
+			assert.NoError(err)
+			assert.Len(pw, int(l))
+			r, q := runs([]rune(pw))
+			assert.True(r <= 1 && q <= 1, pw)
+		}
+	}
+
+	// Runs up to the limits are allowed
+	g.Length = 40
+	g.MaxRepeat, g.MaxSequence = 2, 3
+	rs := map[[2]uint64]bool{}
+	for i := uint64(0); i < 40; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		r, q := runs([]rune(pw))
+		assert.True(r <= 2 && q <= 3, pw)
+		rs[[2]uint64{r, q}] = true
+	}
+	assert.True(rs[[2]uint64{2, 3}])
+
+	// Position templates and minimums are still met
+	g = newG1Opts()
+	g.Length = 8
+	g.Positions = "nn"
+	g.Uppers = 3
+	g.MaxLowers, g.MaxSymbols = 0, 0
+	g.MaxRepeat, g.MaxSequence = 1, 1
+	for i := uint64(0); i < 20; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.True(countIn(pw[:2], "0123456789") == 2, pw)
+		assert.True(countIn(pw, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") >= 3, pw)
+		r, q := runs([]rune(pw))
+		assert.True(r <= 1 && q <= 1, pw)
+	}
+
+	// A single character can only repeat
+	g = newG1Opts()
+	g.Length = 4
+	g.MaxNumbers, g.MaxUppers, g.MaxLowers, g.MaxSymbols = 0, 0, 0, 0
+	g.Classes = []CharClass{{Name: "x", Chars: "x", Max: -1}}
+	g.MaxRepeat = 1
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "No password of length 4 can satisfy the repeat and sequence limits")
+
+	// ab can only alternate, which is a sequence
+	g.Classes = []CharClass{{Name: "ab", Chars: "ab", Max: -1}}
+	g.MaxSequence = 1
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "No password of length 4 can satisfy the repeat and sequence limits")
+
+	// A single x is possible between other characters
+	g.Classes = []CharClass{{Name: "x", Chars: "x", Min: 2, Max: -1}}
+	g.MaxNumbers = -1
+	g.Length = 3
+	for i := uint64(0); i < 10; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.Equal(byte('x'), pw[0], pw)
+		assert.Equal(byte('x'), pw[2], pw)
+	}
+
+	g = newG1Opts()
+	g.GenVersion = 1
+	g.MaxRepeat = 2
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "v1 does not support run limits")
+}
+
+func TestV2MinClasses(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Length = 4
+	g.Uppers, g.Lowers, g.Numbers, g.Symbols = 0, 0, 0, 0
+	g.MinClasses = 3
+	sets := []string{"0123456789", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "abcdefghijklmnopqrstuvwxyz", g.SymbolSet}
+	chosen := map[string]bool{}
+	for i := uint64(0); i < 30; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		used := ""
+		for j, s := range sets {
+			if countIn(pw, s) > 0 {
+				used += fmt.Sprint(j)
+			}
+		}
+		assert.True(len(used) >= 3, pw)
+		chosen[used] = true
+	}
+	// The classes are not always the same
+	assert.True(len(chosen) > 1)
+
+	// A required class counts toward MinClasses
+	g.Length = 3
+	g.Symbols = 1
+	g.Positions = "x"
+	pw, err := m.GenPW(g)
+	assert.NoError(err)
+	assert.Equal(1, countIn(pw, g.SymbolSet), pw)
+
+	g.Length = 2
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Minimum character requirements are greater than the length")
+
+	g.Length = 10
+	g.MaxSymbols = 0
+	g.Symbols = 0
+	g.MinClasses = 4
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Only 3 character classes can be used, 4 are required")
+
+	// Both positions only allow upper case letters
+	g = newG1Opts()
+	g.Length = 2
+	g.Uppers, g.MaxUppers, g.MaxLowers = 1, 2, 0
+	g.MinClasses = 2
+	g.Positions = "au"
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Position template allows only 1 character classes, 2 are required")
+
+	// The classes are met along with the template and the run limits
+	g.Length = 4
+	g.MaxRepeat = 1
+	for i := uint64(0); i < 20; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		used := 0
+		for _, s := range []string{"0123456789", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", g.SymbolSet} {
+			if countIn(pw, s) > 0 {
+				used++
+			}
+		}
+		assert.True(used >= 2, pw)
+		assert.True(countIn(pw[:2], "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == 2, pw)
+	}
+
+	g = newG1Opts()
+	g.GenVersion = 1
+	g.MinClasses = 3
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "v1 does not support min classes")
+}
+
+func TestV2LengthRange(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Length = 12
+	g.MaxLength = 16
+	lengths := map[int]bool{}
+	for i := uint64(0); i < 40; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		n := len([]rune(pw))
+		assert.True(n >= 12 && n <= 16, pw)
+		lengths[n] = true
+	}
+	assert.True(len(lengths) > 1)
+
+	// The length also differs between users
+	lengths = map[int]bool{}
+	for _, u := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		g.Username = u
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		lengths[len(pw)] = true
+	}
+	assert.True(len(lengths) > 1)
+
+	g.MaxLength = 11
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Max length is less than the length")
+
+	// Limits which only allow part of the range are rejected
+	g.MaxLength = 16
+	g.MaxNumbers, g.MaxUppers, g.MaxLowers, g.MaxSymbols = 3, 3, 3, 3
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Maximum character limits are less than the length")
+
+	// Both ends of the range are valid, but at length 3 the second character
+	// would have to be a symbol and an upper case letter
+	g = newG1Opts()
+	g.Length, g.MaxLength = 2, 8
+	g.Uppers, g.MaxUppers, g.MaxLowers, g.MaxSymbols = 1, 2, 2, 2
+	g.Positions, g.PositionsEnd = "xs", "ux"
+	for i := uint64(0); i < 10; i++ {
+		g.Iteration = i
+		_, err = m.GenPW(g)
+		assert.EqualError(err, "Position template can not be filled within the class limits")
+	}
+	g.Length = 4
+	for i := uint64(0); i < 10; i++ {
+		g.Iteration = i
+		_, err = m.GenPW(g)
+		assert.NoError(err)
+	}
+
+	g = newG1Opts()
+	g.GenVersion = 1
+	g.MaxLength = 30
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "v1 does not support length ranges")
+}
+
+// The output of each v2 feature is frozen, these must never change
+func TestV2Pinned(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	for _, tc := range []struct {
+		name string
+		set  func(g *GenOpts)
+		pw   string
+	}{
+		{"classes", func(g *GenOpts) {
+			g.Length = 12
+			g.MaxSymbols = 0
+			g.Classes = []CharClass{
+				{Name: "bang", Chars: "!@#", Min: 1, Max: -1},
+				{Name: "brackets", Chars: "()[]", Min: 1, Max: 2},
+			}
+		}, "oE[Y9)6T5H8!"},
+		{"unicode blocks", func(g *GenOpts) {
+			g.Length = 20
+			g.Classes = []CharClass{
+				{Name: "greek", Blocks: []string{"greek"}, Min: 2, Max: -1},
+				{Name: "emoji", Blocks: []string{"emoji"}, Min: 1, Max: 3},
+			}
+		}, "😯n😘Βσ😍σπTΖSτΐΚξ=Fa#p"},
+		{"exclude", func(g *GenOpts) {
+			g.ExcludeAmbiguous = true
+			g.Exclude = "xyz"
+		}, "F77eb$F@eLKCbWnVDK=/j3vB"},
+		{"runs", func(g *GenOpts) {
+			g.MaxRepeat = 1
+			g.MaxSequence = 2
+		}, "VHyG-a4ImA%T9~;TW34%O%WX"},
+		{"positions", func(g *GenOpts) {
+			g.Positions = "a"
+			g.PositionsEnd = "Asn"
+		}, "lWs75EWichLmFF8-*.M=ZC=7"},
+		{"min classes", func(g *GenOpts) {
+			g.Length = 4
+			g.Uppers, g.Lowers, g.Numbers, g.Symbols = 0, 0, 0, 0
+			g.MinClasses = 3
+		}, "Mk!8"},
+		{"length range", func(g *GenOpts) {
+			g.Length = 12
+			g.MaxLength = 16
+		}, "Ozya8ZK=MPnX9"},
+	} {
+		g := newG1Opts()
+		tc.set(g)
+		pw, err := m.GenPW(g)
+		assert.NoError(err, tc.name)
+		assert.Equal(tc.pw, pw, tc.name)
+	}
+}
+
+// Run limits over a long length range once took a minute per password
+func TestV2RunsLengthRangeTime(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Length, g.MaxLength = 100, 200
+	g.MaxRepeat = 2
+	start := time.Now()
+	for i := uint64(0); i < 5; i++ {
+		g.Iteration = i
+		_, err := m.GenPW(g)
+		assert.NoError(err)
+	}
+	assert.True(time.Since(start) < 5*time.Second, time.Since(start).String())
+}
+
+func BenchmarkV2RunsLengthRange(b *testing.B) {
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	if err != nil {
+		b.Fatal(err)
+	}
+	g := newG1Opts()
+	g.Length, g.MaxLength = 32, 128
+	g.MaxRepeat = 2
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Iteration = uint64(i)
+		if _, err := m.GenPW(g); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestV2OnlyChars(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	for _, mode := range []string{ModeWords, ModePIN, ModePronounceable, ModeKey, ModeSSH, ModeX25519, ModeTOTP, ModeBIP39} {
+		for _, tc := range []struct {
+			set func(g *GenOpts)
+			err string
+		}{
+			{func(g *GenOpts) { g.Classes = []CharClass{{Name: "x", Chars: "x", Max: -1}} }, "Character classes are not supported by mode "},
+			{func(g *GenOpts) { g.MaxRepeat = 2 }, "Repeat and sequence limits are not supported by mode "},
+			{func(g *GenOpts) { g.MaxLength = 30 }, "Length ranges are not supported by mode "},
+			{func(g *GenOpts) { g.MinClasses = 2 }, "Minimum classes are not supported by mode "},
+			{func(g *GenOpts) { g.Positions = "n" }, "Position templates are not supported by mode "},
+		} {
+			g := newG1Opts()
+			g.Mode = mode
+			tc.set(g)
+			_, err := m.GenPW(g)
+			assert.EqualError(err, tc.err+mode)
+		}
+	}
+
+	// The raw keys are checked as well
+	g := newG1Opts()
+	g.Mode = ModeSSH
+	g.MaxLength = 30
+	_, err = m.SSHKey(g)
+	assert.EqualError(err, "Length ranges are not supported by mode ssh")
+
+	// Only words and pronounceable apply the exclusions
+	for _, mode := range []string{ModePIN, ModeKey, ModeSSH, ModeX25519, ModeTOTP, ModeBIP39} {
+		g := newG1Opts()
+		g.Mode = mode
+		g.ExcludeAmbiguous = true
+		_, err := m.GenPW(g)
+		assert.EqualError(err, "Excluding characters is not supported by mode "+mode)
+	}
+	g = newWordsOpts()
+	g.ExcludeAmbiguous = true
+	_, err = m.GenPW(g)
+	assert.NoError(err)
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 
 	"crypto/sha512" // Sha512 is faster on 64 bit CPUs, no reason to not prefer it
-
-	"golang.org/x/crypto/scrypt"
 )
 
 // What kind of salt is that? You can't use a constant salt, what's the point in even
@@ -19,40 +17,46 @@ import (
 // Now don't be so salty.
 const appSalt = "\x81\xf1\xed\x02\t\xd9\\\xff\xdc\b-\xd4\x01\r\x05\xd6"
 
-// HashPw will generate the scrypt hash of the password which will be used to seed
+// HashPw will generate the hash of the password which will be used to seed
 // the prng used by the password generator.
-// HashPw will call Init() if it has not already been called.
+// The KDF is chosen by KDFParams, so the options should be complete before
+// calling HashPw. To reuse one hash for many options, see MasterKey.
 // HashPw will zero pw before returning.
 func (g *GenOpts) HashPw(pw []byte) error {
-	// no matter what, zero the plaintext password
-	defer func() {
+	k, err := g.KDFParams()
+	if err != nil {
+		// no matter what, zero the plaintext password
 		for i := range pw {
 			pw[i] = 0
 		}
-	}()
-
-	hashMP, err := scrypt.Key(pw, []byte(appSalt), 2^10, 8, 1, 512)
+		return err
+	}
+	m, err := NewMasterKey(pw, k)
 	if err != nil {
 		return err
 	}
-	copy(g.mpHash[:], hashMP)
+	g.mk = m
 	return nil
 }
 
-func (g *GenOpts) makeHashStream() (*hashStream, error) {
+func (m *MasterKey) makeHashStream(g *GenOpts) (*hashStream, error) {
 	if g.Domain == "" {
 		return nil, fmt.Errorf("Domain required")
 	}
 	if g.Username == "" {
 		return nil, fmt.Errorf("Username required")
 	}
-	seedSrc := append(g.mpHash[:], []byte(g.Domain)...)
+	seedSrc := append(m.hash[:], []byte(g.Domain)...)
 	seedSrc = append(seedSrc, []byte(g.Username)...)
 
 	bi := make([]byte, 8)
 	binary.BigEndian.PutUint64(bi, g.Iteration)
 	seedSrc = append(seedSrc, bi...)
 
+	// Every mode but the original gets its own stream, so that passwords of
+	// different modes for the same entry are unrelated.
+	seedSrc = append(seedSrc, []byte(g.Mode)...)
+
 	seed := sha512.Sum512(seedSrc)
 	return &hashStream{
 		seed: seed,
@@ -63,20 +67,58 @@ func (g *GenOpts) makeHashStream() (*hashStream, error) {
 type hashStream struct {
 	seed [64]byte
 	ctr  uint64
+	buf  []byte // unread bytes of the last block read by Read
 }
 
-func (h *hashStream) nextInt() uint64 {
+// block returns the next block of the stream
+func (h *hashStream) block() [32]byte {
 	bctr := make([]byte, 8)
 	binary.BigEndian.PutUint64(bctr, h.ctr)
 	s := sha512.Sum512_256(append(h.seed[:], bctr...))
 	h.ctr++
+	return s
+}
+
+func (h *hashStream) nextInt() uint64 {
+	s := h.block()
 	return binary.BigEndian.Uint64(s[:8])
 }
 
+// Read fills p with bytes of the stream. Unlike nextInt, every byte of each
+// block is used.
+func (h *hashStream) Read(p []byte) (int, error) {
+	for i := range p {
+		if len(h.buf) == 0 {
+			s := h.block()
+			h.buf = s[:]
+		}
+		p[i] = h.buf[0]
+		h.buf = h.buf[1:]
+	}
+	return len(p), nil
+}
+
 // returns a deterministic psuedo-random number up to m
+// This is used by genV1 and must not change.
 func (h *hashStream) nextMax(m uint64) uint64 {
 	if m == 0 {
 		return 0
 	}
 	return h.nextInt() % m
 }
+
+// uniform returns a deterministic psuedo-random number up to m without the
+// modulo bias of nextMax. Numbers from the top of the range which would favor
+// the low results are discarded and redrawn.
+func (h *hashStream) uniform(m uint64) uint64 {
+	if m == 0 {
+		return 0
+	}
+	// 2^64 % m, the count of numbers which must be discarded
+	t := -m % m
+	for {
+		if n := h.nextInt(); n >= t {
+			return n % m
+		}
+	}
+}
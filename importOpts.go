@@ -0,0 +1,94 @@
+package dpass
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const nonceLen = 24
+
+// FromBlob hashes the master password and decrypts a blob created by Blob.
+// idx is the BlobIndex the blob was saved under. See MasterKey.OpenBlob.
+// The password is hashed with k, which must be the KDF of the options the blob
+// was saved by. Interfaces which do not know it can try each of KnownKDFs.
+func FromBlob(idx, blob, dom string, pw []byte, k KDFParams) (*GenOpts, error) {
+	m, err := NewMasterKey(pw, k)
+	if err != nil {
+		return nil, err
+	}
+	return m.OpenBlob(dom, idx, blob)
+}
+
+// OpenBlob decrypts a blob for the domain of g using the master password
+// hashed by HashPw, the rest of g is ignored. See MasterKey.OpenBlob.
+func (g *GenOpts) OpenBlob(idx, blob string) (*GenOpts, error) {
+	m, err := g.masterKey()
+	if err != nil {
+		return nil, err
+	}
+	return m.OpenBlob(g.Domain, idx, blob)
+}
+
+// OpenBlob decrypts a blob created by Blob and returns the options it contains.
+// If the returned options use the same KDF as the master key they carry it, so
+// GenPW can be called on them directly. Otherwise the master password must be
+// hashed again for them.
+// idx is the BlobIndex the blob was saved under. It must belong to the domain,
+// and the decrypted options must hash to its suffix, so that a blob can not be
+// passed off under the index of another entry.
+func (m *MasterKey) OpenBlob(dom, idx, blob string) (*GenOpts, error) {
+	bp := m.BlobIndexPrefix(dom)
+	idx = strings.ToUpper(idx)
+	if !strings.HasPrefix(idx, bp) {
+		return nil, fmt.Errorf("Blob index does not belong to domain %s", dom)
+	}
+
+	d, err := base64.URLEncoding.DecodeString(blob)
+	if err != nil {
+		return nil, err
+	}
+	if len(d) < nonceLen+secretbox.Overhead {
+		return nil, fmt.Errorf("Blob is too short")
+	}
+
+	// Split off the nonce and open it
+	var n [nonceLen]byte
+	copy(n[:], d[:nonceLen])
+	dh := m.blobKey(dom)
+	jz, ok := secretbox.Open(nil, d[nonceLen:], &n, &dh)
+	if !ok {
+		return nil, fmt.Errorf("Unable to decrypt blob")
+	}
+
+	// Decompress the json
+	r, err := gzip.NewReader(bytes.NewReader(jz))
+	if err != nil {
+		return nil, err
+	}
+	j, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if bp+jsonKeyOf(j) != idx {
+		return nil, fmt.Errorf("Blob does not match index %s", idx)
+	}
+
+	o, err := FromJSON(j)
+	if err != nil {
+		return nil, err
+	}
+	if o.Domain != dom {
+		return nil, fmt.Errorf("Blob is for domain %s, not %s", o.Domain, dom)
+	}
+	if m.check(o) == nil {
+		o.mk = m
+	}
+	return o, nil
+}
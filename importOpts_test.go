@@ -0,0 +1,94 @@
+package dpass
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	g.Length = 30
+	g.Symbols = 2
+	g.SymbolSet = "!@#"
+	assert.NoError(g.HashPw([]byte(testPw)))
+
+	idx, err := g.BlobIndex()
+	assert.NoError(err)
+	b, err := g.Blob()
+	assert.NoError(err)
+
+	o, err := FromBlob(idx, b, g.Domain, []byte(testPw), DefaultKDF)
+	assert.NoError(err)
+	assert.Equal(g, o)
+
+	pw, err := g.GenPW()
+	assert.NoError(err)
+	opw, err := o.GenPW()
+	assert.NoError(err)
+	assert.Equal(pw, opw)
+}
+
+func TestBlobWrongIndex(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	assert.NoError(g.HashPw([]byte(testPw)))
+	b, err := g.Blob()
+	assert.NoError(err)
+
+	o := newG1Opts()
+	o.Iteration = 1
+	assert.NoError(o.HashPw([]byte(testPw)))
+	oidx, err := o.BlobIndex()
+	assert.NoError(err)
+
+	_, err = FromBlob(oidx, b, g.Domain, []byte(testPw), DefaultKDF)
+	assert.Error(err)
+	_, err = FromBlob(oidx, b, "bar.com", []byte(testPw), DefaultKDF)
+	assert.Error(err)
+}
+
+func TestBlobWrongPassword(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	assert.NoError(g.HashPw([]byte(testPw)))
+	idx, err := g.BlobIndex()
+	assert.NoError(err)
+	b, err := g.Blob()
+	assert.NoError(err)
+
+	_, err = FromBlob(idx, b, g.Domain, []byte("wrong"), DefaultKDF)
+	assert.Error(err)
+}
+
+func TestBlobKDF(t *testing.T) {
+	assert := assert.New(t)
+	for _, k := range []KDFParams{LegacyKDF, DefaultArgon2id} {
+		g := newG1Opts()
+		g.KDF = &k
+		if k == LegacyKDF {
+			g.KDF = nil
+			g.GenVersion = 1
+		}
+		assert.NoError(g.HashPw([]byte(testPw)))
+		idx, err := g.BlobIndex()
+		assert.NoError(err)
+		b, err := g.Blob()
+		assert.NoError(err)
+
+		bp, err := BlobIndexPrefix(g.Domain, []byte(testPw), k)
+		assert.NoError(err)
+		assert.True(strings.HasPrefix(idx, bp))
+		dp, err := BlobIndexPrefix(g.Domain, []byte(testPw), DefaultKDF)
+		assert.NoError(err)
+		assert.NotEqual(bp, dp)
+
+		o, err := FromBlob(idx, b, g.Domain, []byte(testPw), k)
+		assert.NoError(err)
+		assert.Equal(g, o)
+		_, err = FromBlob(idx, b, g.Domain, []byte(testPw), DefaultKDF)
+		assert.Error(err)
+	}
+}
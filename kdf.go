@@ -0,0 +1,100 @@
+package dpass
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+)
+
+// KDFParams are the parameters used to hash the master password.
+// N, R and P are only used by scrypt, Time, Memory and Threads only by argon2id.
+type KDFParams struct {
+	Alg     string `json:"a,omitempty"` // KDFScrypt if empty
+	N       int    `json:"N,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+	Time    uint32 `json:"t,omitempty"`
+	Memory  uint32 `json:"m,omitempty"` // KiB
+	Threads uint8  `json:"th,omitempty"`
+	KeyLen  int    `json:"k"`
+}
+
+// LegacyKDF is the KDF used by GenVersion 1.
+// The cost was meant to be 2^10, but in go ^ is xor, so it has always been 8.
+// It is far too weak, but must be kept for the passwords generated with it.
+var LegacyKDF = KDFParams{N: 2 ^ 10, R: 8, P: 1, KeyLen: 512}
+
+// DefaultKDF is the KDF used by the latest GenVersion
+var DefaultKDF = KDFParams{N: 1 << 15, R: 8, P: 1, KeyLen: 64}
+
+// DefaultArgon2id is the argon2id KDF used when it is selected without parameters,
+// following the second recommended option of RFC 9106.
+var DefaultArgon2id = KDFParams{Alg: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4, KeyLen: 64}
+
+// kdfProfiles maps each GenVersion to the KDF used when the options do not
+// specify their own. Like the generators, a profile must never change once released.
+var kdfProfiles = map[uint64]KDFParams{
+	1: LegacyKDF,
+	2: DefaultKDF,
+}
+
+// KnownKDFs returns every KDF profile and DefaultArgon2id, for interfaces
+// which need to find entries saved with any of them.
+func KnownKDFs() []KDFParams {
+	var ks []KDFParams
+	for v := uint64(1); v <= LatestGenVersion; v++ {
+		k, ok := kdfProfiles[v]
+		if !ok {
+			continue
+		}
+		known := false
+		for _, o := range ks {
+			known = known || o == k
+		}
+		if !known {
+			ks = append(ks, k)
+		}
+	}
+	return append(ks, DefaultArgon2id)
+}
+
+// KDFParams returns the KDF set in the options, or the profile for the GenVersion
+func (g *GenOpts) KDFParams() (KDFParams, error) {
+	if g.KDF != nil {
+		return *g.KDF, nil
+	}
+	k, ok := kdfProfiles[g.GenVersion]
+	if !ok {
+		return KDFParams{}, fmt.Errorf("No KDF profile for generation version %d", g.GenVersion)
+	}
+	return k, nil
+}
+
+func (k KDFParams) String() string {
+	if k.Alg == KDFArgon2id {
+		return fmt.Sprintf("argon2id(t=%d,m=%d,p=%d)", k.Time, k.Memory, k.Threads)
+	}
+	return fmt.Sprintf("scrypt(N=%d,r=%d,p=%d)", k.N, k.R, k.P)
+}
+
+func (k KDFParams) key(pw []byte) ([]byte, error) {
+	if k.KeyLen < 64 {
+		return nil, fmt.Errorf("KDF key length must be at least 64")
+	}
+	switch k.Alg {
+	case "", KDFScrypt:
+		return scrypt.Key(pw, []byte(appSalt), k.N, k.R, k.P, k.KeyLen)
+	case KDFArgon2id:
+		if k.Time == 0 || k.Memory == 0 || k.Threads == 0 {
+			return nil, fmt.Errorf("Argon2id time, memory and threads are required")
+		}
+		return argon2.IDKey(pw, []byte(appSalt), k.Time, k.Memory, k.Threads, uint32(k.KeyLen)), nil
+	}
+	return nil, fmt.Errorf("Unknown KDF %q", k.Alg)
+}
@@ -0,0 +1,59 @@
+package dpass
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyKDF(t *testing.T) {
+	// GenVersion 1 must keep the cost it was released with
+	assert.Equal(t, 8, LegacyKDF.N)
+	k, err := (&GenOpts{GenVersion: 1}).KDFParams()
+	assert.NoError(t, err)
+	assert.Equal(t, LegacyKDF, k)
+}
+
+func TestKDFMismatch(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	assert.NoError(g.HashPw([]byte(testPw)))
+	g.GenVersion = 1
+	_, err := g.GenPW()
+	assert.Error(err)
+
+	g.KDF = &DefaultKDF
+	_, err = g.GenPW()
+	assert.NoError(err)
+}
+
+func TestKnownKDFs(t *testing.T) {
+	assert.Equal(t, []KDFParams{LegacyKDF, DefaultKDF, DefaultArgon2id}, KnownKDFs())
+}
+
+func TestArgon2id(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	k := DefaultArgon2id
+	k.Memory = 1024
+	g.KDF = &k
+	pw, err := GenPW(g, []byte(testPw))
+	assert.NoError(err)
+
+	s := newG1Opts()
+	spw, err := GenPW(s, []byte(testPw))
+	assert.NoError(err)
+	assert.NotEqual(spw, pw)
+
+	j, err := g.JSON()
+	assert.NoError(err)
+	o, err := FromJSON(j)
+	assert.NoError(err)
+	opw, err := GenPW(o, []byte(testPw))
+	assert.NoError(err)
+	assert.Equal(pw, opw)
+
+	k.Threads = 0
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err)
+}
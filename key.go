@@ -0,0 +1,83 @@
+package dpass
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Encodings of the key generated by ModeKey
+const (
+	KeyEncodingHex       = "" // lower case hex
+	KeyEncodingBase64    = "base64"
+	KeyEncodingBase64URL = "base64url" // url safe base64 without padding, as used by JWT
+	KeyEncodingBase32    = "base32"
+	KeyEncodingRaw       = "raw" // the bytes themselves, only returned by GenKey
+)
+
+const (
+	DefaultKeyBytes = 32
+	MaxKeyBytes     = 1024 // far more than any key needs, keeps a typo from allocating gigabytes
+)
+
+// encodeKey encodes a key as a string in one of the KeyEncodings
+func encodeKey(enc string, k []byte) (string, error) {
+	switch enc {
+	case KeyEncodingHex:
+		return hex.EncodeToString(k), nil
+	case KeyEncodingBase64:
+		return base64.StdEncoding.EncodeToString(k), nil
+	case KeyEncodingBase64URL:
+		return base64.RawURLEncoding.EncodeToString(k), nil
+	case KeyEncodingBase32:
+		return base32.StdEncoding.EncodeToString(k), nil
+	case KeyEncodingRaw:
+		return "", fmt.Errorf("Raw keys can not be returned as a string, use GenKey")
+	}
+	return "", fmt.Errorf("Unknown key encoding %q", enc)
+}
+
+// keyV2 reads KeyBytes from the hashStream
+func keyV2(g *GenOpts, h *hashStream) ([]byte, error) {
+	if err := g.onlyChars(false); err != nil {
+		return nil, err
+	}
+	if g.KeyBytes == 0 {
+		return nil, fmt.Errorf("Key bytes must be greater than 0")
+	}
+	if g.KeyBytes > MaxKeyBytes {
+		return nil, fmt.Errorf("Key bytes must not be more than %d", MaxKeyBytes)
+	}
+	k := make([]byte, g.KeyBytes)
+	h.Read(k)
+	return k, nil
+}
+
+// genKeyV2 generates a key of KeyBytes encoded with KeyEncoding
+func genKeyV2(g *GenOpts, h *hashStream) (string, error) {
+	if _, err := encodeKey(g.KeyEncoding, nil); err != nil {
+		return "", err
+	}
+	k, err := keyV2(g, h)
+	if err != nil {
+		return "", err
+	}
+	return encodeKey(g.KeyEncoding, k)
+}
+
+// GenKey will generate the deterministic raw bytes of a key for options of
+// ModeKey. The KeyEncoding is ignored, GenPW returns the encoded key.
+func (m *MasterKey) GenKey(g *GenOpts) ([]byte, error) {
+	return m.derive(g, ModeKey)
+}
+
+// GenKey will generate the raw bytes of a key based on the initialized options
+// and hashed master password.
+func (g *GenOpts) GenKey() ([]byte, error) {
+	m, err := g.masterKey()
+	if err != nil {
+		return nil, err
+	}
+	return m.GenKey(g)
+}
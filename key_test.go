@@ -0,0 +1,114 @@
+package dpass
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newKeyOpts() *GenOpts {
+	g := newG1Opts()
+	g.Mode = ModeKey
+	g.KeyBytes = DefaultKeyBytes
+	return g
+}
+
+func TestGenKey(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newKeyOpts()
+	k, err := m.GenKey(g)
+	assert.NoError(err)
+	assert.Len(k, 32)
+	assert.Equal("d8c4e3e25cf44588f66eb09623c422dbbb2c669bc8cfdca98df41b78c47c971e", hex.EncodeToString(k))
+
+	// Longer keys start with the bytes of shorter keys
+	for _, n := range []uint64{1, 33, 64, 100} {
+		g.KeyBytes = n
+		l, err := m.GenKey(g)
+		assert.NoError(err)
+		assert.Len(l, int(n))
+		if n > 32 {
+			assert.Equal(k, l[:32])
+		} else {
+			assert.Equal(k[:n], l)
+		}
+	}
+
+	g.KeyBytes = 32
+	g.Iteration = 1
+	l, err := m.GenKey(g)
+	assert.NoError(err)
+	assert.NotEqual(k, l)
+
+	g.Iteration = 0
+	g.KeyBytes = 0
+	_, err = m.GenKey(g)
+	assert.Error(err)
+	g.KeyBytes = MaxKeyBytes
+	k, err = m.GenKey(g)
+	assert.NoError(err)
+	assert.Len(k, MaxKeyBytes)
+	g.KeyBytes = 1 << 40
+	_, err = m.GenKey(g)
+	assert.EqualError(err, "Key bytes must not be more than 1024")
+
+	g = newG1Opts()
+	_, err = m.GenKey(g)
+	assert.Error(err, "GenKey requires ModeKey")
+}
+
+func TestKeyEncodings(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newKeyOpts()
+	g.KeyBytes = 20
+	k, err := m.GenKey(g)
+	assert.NoError(err)
+
+	for enc, dec := range map[string]func(string) ([]byte, error){
+		KeyEncodingHex:       hex.DecodeString,
+		KeyEncodingBase64:    base64.StdEncoding.DecodeString,
+		KeyEncodingBase64URL: base64.RawURLEncoding.DecodeString,
+		KeyEncodingBase32:    base32.StdEncoding.DecodeString,
+	} {
+		g.KeyEncoding = enc
+		s, err := m.GenPW(g)
+		assert.NoError(err)
+		d, err := dec(s)
+		assert.NoError(err, enc)
+		assert.Equal(k, d, enc)
+	}
+
+	// Raw keys are only returned as bytes
+	g.KeyEncoding = KeyEncodingRaw
+	_, err = m.GenPW(g)
+	assert.Error(err)
+	l, err := m.GenKey(g)
+	assert.NoError(err)
+	assert.Equal(k, l)
+
+	g.KeyEncoding = "base58"
+	_, err = m.GenPW(g)
+	assert.Error(err)
+
+	// The options round trip through json
+	g.KeyEncoding = KeyEncodingBase64URL
+	j, err := g.JSON()
+	assert.NoError(err)
+	o, err := FromJSON(j)
+	assert.NoError(err)
+	assert.Equal(g.KeyBytes, o.KeyBytes)
+	assert.Equal(g.KeyEncoding, o.KeyEncoding)
+
+	g.GenVersion = 1
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "v1 does not support keys")
+}
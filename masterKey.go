@@ -0,0 +1,96 @@
+package dpass
+
+import "fmt"
+
+// MasterKey is a hashed master password.
+// It never changes once created, so one MasterKey can be used from many
+// goroutines at once to generate passwords and blobs for any options which
+// use the same KDF, without hashing the master password for each of them.
+type MasterKey struct {
+	hash [64]byte
+	kdf  KDFParams
+}
+
+// NewMasterKey hashes the master password with the KDF k.
+// NewMasterKey will zero pw before returning.
+func NewMasterKey(pw []byte, k KDFParams) (*MasterKey, error) {
+	// no matter what, zero the plaintext password
+	defer func() {
+		for i := range pw {
+			pw[i] = 0
+		}
+	}()
+
+	hashMP, err := k.key(pw)
+	if err != nil {
+		return nil, err
+	}
+	m := &MasterKey{kdf: k}
+	copy(m.hash[:], hashMP)
+	return m, nil
+}
+
+// KDF returns the KDF the master key was hashed with
+func (m *MasterKey) KDF() KDFParams {
+	return m.kdf
+}
+
+// check ensures that the options use the same KDF as the master key
+func (m *MasterKey) check(g *GenOpts) error {
+	k, err := g.KDFParams()
+	if err != nil {
+		return err
+	}
+	if k != m.kdf {
+		return fmt.Errorf("Master key was hashed with %s, but the options require %s", m.kdf, k)
+	}
+	return nil
+}
+
+// GenPW will generate a deterministic password for the options
+func (m *MasterKey) GenPW(g *GenOpts) (string, error) {
+	if err := m.check(g); err != nil {
+		return "", err
+	}
+	gens, ok := generators[g.GenVersion]
+	if !ok {
+		return "", fmt.Errorf("Unknown generation version %d", g.GenVersion)
+	}
+	gen, ok := gens[g.Mode]
+	if !ok {
+		return "", fmt.Errorf("Mode %q is not supported by generation version %d", g.Mode, g.GenVersion)
+	}
+	h, err := m.makeHashStream(g)
+	if err != nil {
+		return "", err
+	}
+	return gen(g, h)
+}
+
+// derive reads the raw secret of options of a key mode with the deriver of
+// their GenVersion
+func (m *MasterKey) derive(g *GenOpts, mode string) ([]byte, error) {
+	if g.Mode != mode {
+		return nil, fmt.Errorf("Mode %q is required, the options are of mode %q", mode, g.Mode)
+	}
+	if err := m.check(g); err != nil {
+		return nil, err
+	}
+	d, ok := derivers[g.GenVersion][mode]
+	if !ok {
+		return nil, fmt.Errorf("Mode %q is not supported by generation version %d", mode, g.GenVersion)
+	}
+	h, err := m.makeHashStream(g)
+	if err != nil {
+		return nil, err
+	}
+	return d(g, h)
+}
+
+// masterKey returns the master key set by HashPw
+func (g *GenOpts) masterKey() (*MasterKey, error) {
+	if g.mk == nil {
+		return nil, fmt.Errorf("No password has been hashed yet.")
+	}
+	return g.mk, nil
+}
@@ -0,0 +1,64 @@
+package dpass
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasterKeyConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	gs := make([]*GenOpts, 20)
+	want := make([]string, len(gs))
+	for i := range gs {
+		gs[i] = NewGenOpts(fmt.Sprintf("user%d", i), "foo.com")
+		gs[i].Length = uint64(8 + i)
+	}
+	// The same options hashed separately generate the same password
+	for i := range gs {
+		o := NewGenOpts(gs[i].Username, gs[i].Domain)
+		o.Length = gs[i].Length
+		want[i], err = GenPW(o, []byte(testPw))
+		assert.NoError(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range gs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pw, err := m.GenPW(gs[i])
+			assert.NoError(err)
+			assert.Equal(want[i], pw)
+			idx, err := m.BlobIndex(gs[i])
+			assert.NoError(err)
+			b, err := m.Blob(gs[i])
+			assert.NoError(err)
+			o, err := m.OpenBlob(gs[i].Domain, idx, b)
+			assert.NoError(err)
+			pw, err = o.GenPW()
+			assert.NoError(err)
+			assert.Equal(want[i], pw)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMasterKeyKDFMismatch(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), LegacyKDF)
+	assert.NoError(err)
+	g := newG1Opts()
+	_, err = m.GenPW(g)
+	assert.Error(err)
+	_, err = m.Blob(g)
+	assert.Error(err)
+	g.GenVersion = 1
+	_, err = m.GenPW(g)
+	assert.NoError(err)
+}
@@ -0,0 +1,167 @@
+package dpass
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"time"
+)
+
+// Hash algorithms of the codes of ModeTOTP
+const (
+	OTPAlgorithmSHA1   = "" // supported by every authenticator app
+	OTPAlgorithmSHA256 = "SHA256"
+	OTPAlgorithmSHA512 = "SHA512"
+)
+
+const (
+	DefaultOTPPeriod = 30
+	DefaultOTPDigits = 6
+)
+
+// otpHash returns the hash of the OTPAlgorithm, and the size of the secrets
+// recommended for it by RFC 6238
+func (g *GenOpts) otpHash() (func() hash.Hash, int, error) {
+	switch g.OTPAlgorithm {
+	case OTPAlgorithmSHA1:
+		return sha1.New, sha1.Size, nil
+	case OTPAlgorithmSHA256:
+		return sha256.New, sha256.Size, nil
+	case OTPAlgorithmSHA512:
+		return sha512.New, sha512.Size, nil
+	}
+	return nil, 0, fmt.Errorf("Unknown OTP algorithm %q", g.OTPAlgorithm)
+}
+
+// otpPeriod returns the OTPPeriod, or the DefaultOTPPeriod if it is not set
+func (g *GenOpts) otpPeriod() uint64 {
+	if g.OTPPeriod == 0 {
+		return DefaultOTPPeriod
+	}
+	return g.OTPPeriod
+}
+
+// otpDigits returns the OTPDigits, or the DefaultOTPDigits if it is not set
+func (g *GenOpts) otpDigits() (uint64, error) {
+	if g.OTPDigits == 0 {
+		return DefaultOTPDigits, nil
+	}
+	if g.OTPDigits < 6 || g.OTPDigits > 8 {
+		return 0, fmt.Errorf("OTP digits must be between 6 and 8")
+	}
+	return g.OTPDigits, nil
+}
+
+// otpSecretV2 reads a secret of the size of the OTPAlgorithm from the hashStream
+func otpSecretV2(g *GenOpts, h *hashStream) ([]byte, error) {
+	if err := g.onlyChars(false); err != nil {
+		return nil, err
+	}
+	_, n, err := g.otpHash()
+	if err != nil {
+		return nil, err
+	}
+	s := make([]byte, n)
+	h.Read(s)
+	return s, nil
+}
+
+// otpEncoding is the base32 used by authenticator apps, without padding
+var otpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// OTPEncode encodes a shared secret as base32 for an authenticator app
+func OTPEncode(secret []byte) string {
+	return otpEncoding.EncodeToString(secret)
+}
+
+// genTOTPV2 returns the base32 shared secret of the options
+func genTOTPV2(g *GenOpts, h *hashStream) (string, error) {
+	s, err := otpSecretV2(g, h)
+	if err != nil {
+		return "", err
+	}
+	return OTPEncode(s), nil
+}
+
+// OTPSecret will generate the deterministic shared secret for options of
+// ModeTOTP. GenPW returns the same secret encoded as base32.
+func (m *MasterKey) OTPSecret(g *GenOpts) ([]byte, error) {
+	return m.derive(g, ModeTOTP)
+}
+
+// OTPSecret will generate the shared secret based on the initialized options
+// and hashed master password.
+func (g *GenOpts) OTPSecret() ([]byte, error) {
+	m, err := g.masterKey()
+	if err != nil {
+		return nil, err
+	}
+	return m.OTPSecret(g)
+}
+
+// OTPURI returns the otpauth:// URI to enroll the secret in an authenticator
+// app, with the Domain as the issuer
+func (g *GenOpts) OTPURI(secret []byte) (string, error) {
+	d, err := g.otpDigits()
+	if err != nil {
+		return "", err
+	}
+	alg := g.OTPAlgorithm
+	if alg == OTPAlgorithmSHA1 {
+		alg = "SHA1"
+	}
+	v := url.Values{}
+	v.Set("secret", OTPEncode(secret))
+	v.Set("issuer", g.Domain)
+	v.Set("algorithm", alg)
+	v.Set("digits", fmt.Sprint(d))
+	v.Set("period", fmt.Sprint(g.otpPeriod()))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + g.Domain + ":" + g.Username,
+		RawQuery: v.Encode(),
+	}
+	return u.String(), nil
+}
+
+// HOTP returns the RFC 4226 code of the secret for a counter, with the
+// OTPDigits and OTPAlgorithm of the options
+func (g *GenOpts) HOTP(secret []byte, counter uint64) (string, error) {
+	hf, _, err := g.otpHash()
+	if err != nil {
+		return "", err
+	}
+	d, err := g.otpDigits()
+	if err != nil {
+		return "", err
+	}
+	c := make([]byte, 8)
+	binary.BigEndian.PutUint64(c, counter)
+	mac := hmac.New(hf, secret)
+	mac.Write(c)
+	s := mac.Sum(nil)
+	o := s[len(s)-1] & 0xf
+	n := uint64(binary.BigEndian.Uint32(s[o:o+4]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := uint64(0); i < d; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", d, n%mod), nil
+}
+
+// TOTP returns the RFC 6238 code of the secret at a time, and the time until
+// the next code
+func (g *GenOpts) TOTP(secret []byte, t time.Time) (string, time.Duration, error) {
+	p := g.otpPeriod()
+	u := uint64(t.Unix())
+	code, err := g.HOTP(secret, u/p)
+	left := time.Duration(p-u%p) * time.Second
+	return code, left, err
+}
@@ -0,0 +1,94 @@
+package dpass
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPVectors(t *testing.T) {
+	assert := assert.New(t)
+	// Test vectors from RFC 6238
+	secrets := map[string]string{
+		OTPAlgorithmSHA1:   "12345678901234567890",
+		OTPAlgorithmSHA256: "12345678901234567890123456789012",
+		OTPAlgorithmSHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	codes := map[int64][3]string{
+		59:         {"94287082", "46119246", "90693936"},
+		1111111109: {"07081804", "68084774", "25091201"},
+		2000000000: {"69279037", "90698825", "38618901"},
+	}
+	g := &GenOpts{OTPDigits: 8}
+	for ts, cs := range codes {
+		for i, alg := range []string{OTPAlgorithmSHA1, OTPAlgorithmSHA256, OTPAlgorithmSHA512} {
+			g.OTPAlgorithm = alg
+			c, left, err := g.TOTP([]byte(secrets[alg]), time.Unix(ts, 0))
+			assert.NoError(err)
+			assert.Equal(cs[i], c, "%s %d", alg, ts)
+			assert.Equal(time.Duration(30-ts%30)*time.Second, left)
+		}
+	}
+
+	// RFC 4226
+	g = &GenOpts{}
+	for i, c := range []string{"755224", "287082", "359152", "969429"} {
+		h, err := g.HOTP([]byte(secrets[OTPAlgorithmSHA1]), uint64(i))
+		assert.NoError(err)
+		assert.Equal(c, h)
+	}
+
+	g.OTPDigits = 9
+	_, err := g.HOTP(nil, 0)
+	assert.Error(err)
+	g.OTPDigits = 6
+	g.OTPAlgorithm = "MD5"
+	_, err = g.HOTP(nil, 0)
+	assert.Error(err)
+}
+
+func TestOTPSecret(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	g.Mode = ModeTOTP
+	assert.NoError(g.HashPw([]byte(testPw)))
+
+	s, err := g.OTPSecret()
+	assert.NoError(err)
+	assert.Len(s, 20)
+	b32, err := g.GenPW()
+	assert.NoError(err)
+	assert.Equal("SMEHPDB5PGAW7ZJXVB5JHPMQNNIRXUAT", b32)
+	assert.Equal(OTPEncode(s), b32)
+	assert.Len(b32, 32)
+
+	u, err := g.OTPURI(s)
+	assert.NoError(err)
+	assert.Equal("otpauth://totp/foo.com:foo?algorithm=SHA1&digits=6&issuer=foo.com&period=30&secret="+b32, u)
+
+	g.OTPAlgorithm = OTPAlgorithmSHA512
+	g.OTPDigits = 8
+	g.OTPPeriod = 60
+	l, err := g.OTPSecret()
+	assert.NoError(err)
+	assert.Len(l, 64)
+	assert.Equal(s, l[:20])
+	u, err = g.OTPURI(l)
+	assert.NoError(err)
+	assert.True(strings.Contains(u, "algorithm=SHA512&digits=8&issuer=foo.com&period=60"), u)
+
+	// The OTP options are stored with the options
+	j, err := g.JSON()
+	assert.NoError(err)
+	o, err := FromJSON(j)
+	assert.NoError(err)
+	assert.Equal(g.OTPAlgorithm, o.OTPAlgorithm)
+	assert.Equal(g.OTPDigits, o.OTPDigits)
+	assert.Equal(g.OTPPeriod, o.OTPPeriod)
+
+	g.Mode = ModeKey
+	_, err = g.OTPSecret()
+	assert.Error(err)
+}
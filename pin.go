@@ -0,0 +1,71 @@
+package dpass
+
+import "fmt"
+
+const DefaultPINLength = 6
+
+// commonPINsV2 are the most used PINs, rejected by genPINV2 when PINBlockCommon
+// is set. PINs which are a single repeated digit or a run are always rejected,
+// so they are not listed here. Like the generator, the list must never change.
+var commonPINsV2 = []string{
+	"1212", "1004", "2000", "6969", "1122", "1313", "2001", "1010", "2580",
+	"0852", "1998", "1999", "2002", "2020", "1357", "2468", "1000", "4545",
+	"1230", "0007", "0101", "1414",
+	"121212", "123123", "112233", "789456", "159753", "102030", "131313",
+	"696969", "147258", "100000", "000123", "147852", "222333", "252525",
+}
+
+// maxPINAttempts limits how many PINs genPINV2 will draw before giving up,
+// which can only happen with a very short PIN and a long blocklist.
+const maxPINAttempts = 10000
+
+// weakPIN returns true for a PIN of a single repeated digit, or an ascending or
+// descending run such as 3456 or 8765. Runs may wrap around from 9 to 0.
+func weakPIN(pin []rune) bool {
+	same, up, down := true, true, true
+	for i := 1; i < len(pin); i++ {
+		d := (pin[i] - pin[i-1] + 10) % 10
+		same = same && d == 0
+		up = up && d == 1
+		down = down && d == 9
+	}
+	return same || up || down
+}
+
+func (g *GenOpts) blockedPIN(pin string) bool {
+	if g.PINBlockCommon {
+		for _, b := range commonPINsV2 {
+			if b == pin {
+				return true
+			}
+		}
+	}
+	for _, b := range g.PINBlocklist {
+		if b == pin {
+			return true
+		}
+	}
+	return false
+}
+
+// genPINV2 generates a numeric PIN of Length digits. Weak and blocked PINs are
+// skipped by drawing the next PIN from the hashStream.
+func genPINV2(g *GenOpts, h *hashStream) (string, error) {
+	if err := g.onlyChars(false); err != nil {
+		return "", err
+	}
+	if g.Length < 2 {
+		return "", fmt.Errorf("PIN length must be at least 2")
+	}
+	digits := charRange('0', '9')
+	pin := make([]rune, g.Length)
+	for i := 0; i < maxPINAttempts; i++ {
+		for j := range pin {
+			pin[j] = digits[h.uniform(uint64(len(digits)))]
+		}
+		if !weakPIN(pin) && !g.blockedPIN(string(pin)) {
+			return string(pin), nil
+		}
+	}
+	return "", fmt.Errorf("Unable to find a PIN which is not blocked")
+}
@@ -0,0 +1,60 @@
+package dpass
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeakPIN(t *testing.T) {
+	for _, p := range []string{"1111", "1234", "4321", "7890", "0987", "123456", "00"} {
+		assert.True(t, weakPIN([]rune(p)), p)
+	}
+	for _, p := range []string{"1121", "1243", "7891", "135790", "13"} {
+		assert.False(t, weakPIN([]rune(p)), p)
+	}
+}
+
+func TestPIN(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Mode = ModePIN
+	g.Length = 6
+	pin, err := m.GenPW(g)
+	assert.NoError(err)
+	assert.Equal("644032", pin)
+
+	for i := uint64(0); i < 50; i++ {
+		g := newG1Opts()
+		g.Mode = ModePIN
+		g.Length = 4
+		g.Iteration = i
+		g.PINBlockCommon = true
+		pin, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.Len(pin, 4)
+		assert.Equal(4, countIn(pin, "0123456789"), pin)
+		assert.False(weakPIN([]rune(pin)), pin)
+		assert.NotContains(commonPINsV2, pin)
+
+		// Blocking the PIN skips to the next one
+		g.PINBlocklist = []string{pin}
+		next, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.NotEqual(pin, next)
+	}
+
+	// Every 2 digit PIN blocked
+	g = newG1Opts()
+	g.Mode = ModePIN
+	g.Length = 2
+	for i := 0; i < 100; i++ {
+		g.PINBlocklist = append(g.PINBlocklist, fmt.Sprintf("%02d", i))
+	}
+	_, err = m.GenPW(g)
+	assert.Error(err)
+}
@@ -0,0 +1,267 @@
+package dpass
+
+import "fmt"
+
+// Characters of the Positions and PositionsEnd templates
+const (
+	PosAny          = 'x' // any class, including the custom Classes
+	PosNumber       = 'n'
+	PosUpper        = 'u'
+	PosLower        = 'l'
+	PosSymbol       = 's'
+	PosLetter       = 'a' // an upper or lower case letter
+	PosAlphanumeric = 'A' // a letter or a number
+)
+
+// positional returns true if the options restrict the class of any position
+func (g *GenOpts) positional() bool {
+	return g.Positions != "" || g.PositionsEnd != ""
+}
+
+// posClasses returns the names of the classes a template character allows, or
+// nil for any class
+func posClasses(t rune) ([]string, error) {
+	switch t {
+	case PosAny:
+		return nil, nil
+	case PosNumber:
+		return []string{"numbers"}, nil
+	case PosUpper:
+		return []string{"uppers"}, nil
+	case PosLower:
+		return []string{"lowers"}, nil
+	case PosSymbol:
+		return []string{"symbols"}, nil
+	case PosLetter:
+		return []string{"uppers", "lowers"}, nil
+	case PosAlphanumeric:
+		return []string{"numbers", "uppers", "lowers"}, nil
+	}
+	return nil, fmt.Errorf("Unknown position template character %q", t)
+}
+
+// allowed returns for each position of the password which of the classes may
+// be used in it. Positions applies from the first character, and PositionsEnd
+// is aligned to the last character.
+func (g *GenOpts) allowed(cs []*class, length uint64) ([][]bool, error) {
+	start, end := []rune(g.Positions), []rune(g.PositionsEnd)
+	if uint64(len(start)) > length || uint64(len(end)) > length {
+		return nil, fmt.Errorf("Position template is longer than the length")
+	}
+	a := make([][]bool, length)
+	for i := range a {
+		a[i] = make([]bool, len(cs))
+		for j := range cs {
+			a[i][j] = true
+		}
+	}
+	restrict := func(p uint64, t rune) error {
+		names, err := posClasses(t)
+		if err != nil || names == nil {
+			return err
+		}
+		for j, c := range cs {
+			ok := false
+			for _, n := range names {
+				ok = ok || c.name == n
+			}
+			a[p][j] = a[p][j] && ok
+		}
+		return nil
+	}
+	for i, t := range start {
+		if err := restrict(uint64(i), t); err != nil {
+			return nil, err
+		}
+	}
+	for i, t := range end {
+		if err := restrict(length-uint64(len(end)-i), t); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// feasible returns true if the free positions of pw can be filled without
+// breaking the min or max of a class, and with at least MinClasses classes
+func (g *GenOpts) feasible(cs []*class, a [][]bool, pw []rune) bool {
+	n, ok := maxClasses(cs, a, pw)
+	return ok && n >= g.MinClasses
+}
+
+// maxClasses returns how many classes can be used at least once when filling
+// the free positions of pw, or false if they can not be filled at all.
+// Classes are added while they leave the positions possible to fill.
+func maxClasses(cs []*class, a [][]bool, pw []rune) (uint64, bool) {
+	if !flowFeasible(cs, a, pw) {
+		return 0, false
+	}
+	n := uint64(0)
+	var raised []*class
+	for _, c := range cs {
+		if c.cur > 0 || c.min > 0 {
+			n++
+			continue
+		}
+		if c.max == 0 {
+			continue
+		}
+		c.min = 1
+		if flowFeasible(cs, a, pw) {
+			n++
+			raised = append(raised, c)
+		} else {
+			c.min = 0
+		}
+	}
+	for _, c := range raised {
+		c.min = 0
+	}
+	return n, true
+}
+
+// flowFeasible returns true if the free positions of pw can be filled without
+// breaking the min or max of a class.
+// It is a flow with lower bounds from the classes, through the positions which
+// allow them, to the sink. Positions which allow the same classes are one node,
+// so the flow only grows with the number of distinct templates, not the length.
+func flowFeasible(cs []*class, a [][]bool, pw []rune) bool {
+	var groups []int // a position of each distinct row of a
+	var counts []int // free positions of each group
+	for i, r := range pw {
+		if r != 0 {
+			continue
+		}
+		k := 0
+		for ; k < len(groups); k++ {
+			if sameRow(a[groups[k]], a[i]) {
+				break
+			}
+		}
+		if k == len(groups) {
+			groups = append(groups, i)
+			counts = append(counts, 0)
+		}
+		counts[k]++
+	}
+	// nodes: s, t, lower bound source and sink, classes, position groups
+	const s, t, ls, lt = 0, 1, 2, 3
+	n := 4 + len(cs) + len(groups)
+	res := make([][]int, n)
+	for i := range res {
+		res[i] = make([]int, n)
+	}
+	need, free := 0, 0
+	for j, c := range cs {
+		lo, hi := 0, 0
+		if c.max > c.cur {
+			hi = int(c.max - c.cur)
+		}
+		if c.min > c.cur {
+			lo = int(c.min - c.cur)
+		}
+		res[s][4+j] = hi - lo
+		res[ls][4+j] += lo
+		res[s][lt] += lo
+		need += lo
+		for k, p := range groups {
+			if a[p][j] {
+				res[4+j][4+len(cs)+k] = counts[k]
+			}
+		}
+	}
+	for k, c := range counts {
+		// every position must be filled exactly once
+		res[ls][t] += c
+		res[4+len(cs)+k][lt] += c
+		need += c
+		free += c
+	}
+	res[t][s] = free + need
+
+	flow := 0
+	for {
+		prev := make([]int, n)
+		for i := range prev {
+			prev[i] = -1
+		}
+		prev[ls] = ls
+		q := []int{ls}
+		for len(q) > 0 && prev[lt] == -1 {
+			u := q[0]
+			q = q[1:]
+			for v := 0; v < n; v++ {
+				if prev[v] == -1 && res[u][v] > 0 {
+					prev[v] = u
+					q = append(q, v)
+				}
+			}
+		}
+		if prev[lt] == -1 {
+			return flow == need
+		}
+		f := need
+		for v := lt; v != ls; v = prev[v] {
+			if res[prev[v]][v] < f {
+				f = res[prev[v]][v]
+			}
+		}
+		for v := lt; v != ls; v = prev[v] {
+			res[prev[v]][v] -= f
+			res[v][prev[v]] += f
+		}
+		flow += f
+	}
+}
+
+// sameRow returns true if two positions allow the same classes
+func sameRow(x, y []bool) bool {
+	for j := range x {
+		if x[j] != y[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// fillPositions generates a password of length from classes validated by
+// validateV2, with the class of each position restricted by a.
+// The positions are filled in a random order, each from the classes which
+// leave the remaining positions possible to fill.
+func (g *GenOpts) fillPositions(h *hashStream, cs []*class, a [][]bool, length uint64) []rune {
+	order := make([]uint64, length)
+	for i := range order {
+		order[i] = uint64(i)
+	}
+	for i := len(order) - 1; i > 0; i-- {
+		j := h.uniform(uint64(i + 1))
+		order[i], order[j] = order[j], order[i]
+	}
+
+	pw := make([]rune, length)
+	for _, p := range order {
+		var pool chars
+		var owner []*class
+		for j, c := range cs {
+			if !a[p][j] || c.cur >= c.max {
+				continue
+			}
+			pw[p] = c.chars[0]
+			c.cur++
+			ok := g.feasible(cs, a, pw)
+			pw[p] = 0
+			c.cur--
+			if !ok {
+				continue
+			}
+			pool = append(pool, c.chars...)
+			for range c.chars {
+				owner = append(owner, c)
+			}
+		}
+		j := h.uniform(uint64(len(pool)))
+		pw[p] = pool[j]
+		owner[j].cur++
+	}
+	return pw
+}
@@ -0,0 +1,74 @@
+package dpass
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositions(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Uppers, g.Lowers, g.Numbers, g.Symbols = 2, 2, 2, 2
+	g.Positions = "a"
+	g.PositionsEnd = "Asn"
+	g.Classes = []CharClass{{Name: "brackets", Chars: "()", Min: 1, Max: -1}}
+	for i := uint64(0); i < 30; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		r := []rune(pw)
+		assert.Len(r, int(g.Length))
+		assert.True(unicode.IsLetter(r[0]), pw)
+		assert.True(unicode.IsLetter(r[len(r)-3]) || unicode.IsDigit(r[len(r)-3]), pw)
+		assert.True(strings.ContainsRune(g.SymbolSet, r[len(r)-2]), pw)
+		assert.True(unicode.IsDigit(r[len(r)-1]), pw)
+		assert.True(countIn(pw, "()") >= 1, pw)
+		assert.True(countIn(pw, g.SymbolSet) >= 2, pw)
+	}
+
+	// The template is part of the serialized options
+	j, err := g.JSON()
+	assert.NoError(err)
+	o, err := FromJSON(j)
+	assert.NoError(err)
+	assert.Equal(g.Positions, o.Positions)
+	assert.Equal(g.PositionsEnd, o.PositionsEnd)
+}
+
+func TestPositionsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Length = 4
+	g.Positions = "nnnn"
+	g.Uppers = 1
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Position template can not be filled within the class limits")
+
+	g.Uppers = 0
+	g.MaxNumbers = 3
+	_, err = m.GenPW(g)
+	assert.Error(err, "too many numbers")
+
+	g.Positions = "nnnnn"
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Position template is longer than the length")
+
+	g.Positions = "q"
+	_, err = m.GenPW(g)
+	assert.EqualError(err, `Unknown position template character 'q'`)
+
+	g = newG1Opts()
+	g.GenVersion = 1
+	g.Positions = "a"
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "v1 does not support positions")
+}
@@ -0,0 +1,178 @@
+package dpass
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Characters of a PronounceTemplate, following the templates of Spectre
+// (formerly Master Password).
+const (
+	TmplUpperVowel     = 'V'
+	TmplUpperConsonant = 'C'
+	TmplLowerVowel     = 'v'
+	TmplLowerConsonant = 'c'
+	TmplUpper          = 'A' // any upper case letter
+	TmplLetter         = 'a' // any letter
+	TmplNumber         = 'n'
+	TmplSymbol         = 'o' // a character from SymbolSet
+	TmplAny            = 'x' // any letter, number or symbol
+)
+
+const (
+	vowels     = "aeiou"
+	consonants = "bcdfghjklmnpqrstvwxyz"
+)
+
+// defaultPronounceSyllable is repeated to the Length of the options when no
+// PronounceTemplate is set
+const defaultPronounceSyllable = "cvc"
+
+// pronounceChars returns the characters allowed by a template character,
+// without the excluded characters
+func (g *GenOpts) pronounceChars(t rune) (chars, error) {
+	cs, err := g.templateChars(t)
+	if err != nil {
+		return nil, err
+	}
+	cs = cs.remove(g.excluded()...)
+	if len(cs) == 0 {
+		return nil, fmt.Errorf("Every character of template character %q is excluded", t)
+	}
+	return cs, nil
+}
+
+func (g *GenOpts) templateChars(t rune) (chars, error) {
+	upper := chars(strings.ToUpper(vowels + consonants))
+	lower := chars(vowels + consonants)
+	switch t {
+	case TmplUpperVowel:
+		return chars(strings.ToUpper(vowels)), nil
+	case TmplUpperConsonant:
+		return chars(strings.ToUpper(consonants)), nil
+	case TmplLowerVowel:
+		return chars(vowels), nil
+	case TmplLowerConsonant:
+		return chars(consonants), nil
+	case TmplUpper:
+		return upper, nil
+	case TmplLetter:
+		return append(upper, lower...), nil
+	case TmplNumber:
+		return charRange('0', '9'), nil
+	case TmplSymbol:
+		if g.SymbolSet == "" {
+			return nil, fmt.Errorf("Symbol set required for template character %q", t)
+		}
+		return chars(g.SymbolSet), nil
+	case TmplAny:
+		cs := append(append(upper, lower...), charRange('0', '9')...)
+		return append(cs, chars(g.SymbolSet)...), nil
+	}
+	return nil, fmt.Errorf("Unknown template character %q", t)
+}
+
+// pronounceTemplate returns the PronounceTemplate, or the default syllables
+// repeated to Length
+func (g *GenOpts) pronounceTemplate() string {
+	if g.PronounceTemplate != "" {
+		return g.PronounceTemplate
+	}
+	n := int(g.Length)/len(defaultPronounceSyllable) + 1
+	return strings.Repeat(defaultPronounceSyllable, n)[:g.Length]
+}
+
+// genPronounceV2 fills each position of the template from its characters.
+// If the result does not meet the Numbers or Symbols minimums, the lower case
+// letters at the end of the password are replaced with them so the start stays
+// pronounceable. Then random lower case letters are upper cased for Uppers.
+// Excluded characters are never used.
+func genPronounceV2(g *GenOpts, h *hashStream) (string, error) {
+	if err := g.onlyChars(true); err != nil {
+		return "", err
+	}
+	t := []rune(g.pronounceTemplate())
+	if len(t) == 0 {
+		return "", fmt.Errorf("Length must be greater than 0")
+	}
+
+	pw := make([]rune, len(t))
+	for i, tr := range t {
+		cs, err := g.pronounceChars(tr)
+		if err != nil {
+			return "", err
+		}
+		pw[i] = cs[h.uniform(uint64(len(cs)))]
+	}
+
+	count := func(cs chars) uint64 {
+		n := uint64(0)
+		for _, r := range pw {
+			if cs.index(r) != -1 {
+				n++
+			}
+		}
+		return n
+	}
+	// lowers returns the positions of lower case letters, from the end
+	lowers := func() []int {
+		var ps []int
+		for i := len(pw) - 1; i >= 0; i-- {
+			if unicode.IsLower(pw[i]) && strings.ContainsRune(vowels+consonants, pw[i]) {
+				ps = append(ps, i)
+			}
+		}
+		return ps
+	}
+
+	nums := charRange('0', '9').remove(g.excluded()...)
+	syms := chars(g.SymbolSet).remove(g.excluded()...)
+	var tail chars // numbers and symbols to place at the end
+	for n := count(nums); n < g.Numbers; n++ {
+		if len(nums) == 0 {
+			return "", fmt.Errorf("Every number is excluded")
+		}
+		tail = append(tail, nums[h.uniform(uint64(len(nums)))])
+	}
+	for n := count(syms); n < g.Symbols; n++ {
+		if len(syms) == 0 {
+			return "", fmt.Errorf("Symbol set required for the symbols minimum")
+		}
+		tail = append(tail, syms[h.uniform(uint64(len(syms)))])
+	}
+	ps := lowers()
+	if len(tail) > len(ps) {
+		return "", fmt.Errorf("Template has too few letters to meet the minimums")
+	}
+	// shuffle the tail so numbers do not always come before symbols
+	for i := len(tail) - 1; i > 0; i-- {
+		j := h.uniform(uint64(i + 1))
+		tail[i], tail[j] = tail[j], tail[i]
+	}
+	for i, r := range tail {
+		pw[ps[len(tail)-1-i]] = r
+	}
+
+	// letters which would be excluded when upper cased can not be used
+	ps = lowers()
+	if x := g.excluded(); len(x) > 0 {
+		var ups []int
+		for _, p := range ps {
+			if x.index(unicode.ToUpper(pw[p])) == -1 {
+				ups = append(ups, p)
+			}
+		}
+		ps = ups
+	}
+	for n := count(chars(strings.ToUpper(vowels + consonants))); n < g.Uppers; n++ {
+		if len(ps) == 0 {
+			return "", fmt.Errorf("Template has too few letters to meet the minimums")
+		}
+		j := h.uniform(uint64(len(ps)))
+		pw[ps[j]] = unicode.ToUpper(pw[ps[j]])
+		ps = append(ps[:j], ps[j+1:]...)
+	}
+
+	return string(pw), nil
+}
@@ -0,0 +1,53 @@
+package dpass
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPronounceable(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Mode = ModePronounceable
+	g.Length = 12
+	pw, err := m.GenPW(g)
+	assert.NoError(err)
+	assert.Equal("qutvegcuzcom", pw)
+	assert.Len(pw, 12)
+	for i, r := range pw {
+		if i%3 == 1 {
+			assert.Contains(vowels, string(r), pw)
+		} else {
+			assert.Contains(consonants, string(r), pw)
+		}
+	}
+
+	g.PronounceTemplate = "CvccvcnoCvcv"
+	pw, err = m.GenPW(g)
+	assert.NoError(err)
+	assert.Len(pw, 12)
+	assert.Contains(strings.ToUpper(consonants), pw[:1])
+	assert.Contains("0123456789", pw[6:7])
+	assert.Contains(g.SymbolSet, pw[7:8])
+
+	g.Numbers, g.Symbols, g.Uppers = 3, 2, 4
+	pw, err = m.GenPW(g)
+	assert.NoError(err)
+	assert.Len(pw, 12)
+	assert.Equal(3, countIn(pw, "0123456789"), pw)
+	assert.Equal(2, countIn(pw, g.SymbolSet), pw)
+	assert.Equal(4, countIn(pw, strings.ToUpper(vowels+consonants)), pw)
+
+	g.PronounceTemplate = "cvn"
+	_, err = m.GenPW(g)
+	assert.Error(err, "not enough letters for the minimums")
+
+	g.PronounceTemplate = "cvq"
+	_, err = m.GenPW(g)
+	assert.Error(err, "unknown template character")
+}
@@ -0,0 +1,141 @@
+package dpass
+
+import "fmt"
+
+// seqGroups are the ranges in which consecutive characters form a sequence
+var seqGroups = [][2]rune{{'0', '9'}, {'a', 'z'}, {'A', 'Z'}}
+
+// sequential returns true if b follows a in either direction, such as ab, ba or 78
+func sequential(a, b rune) bool {
+	if b != a+1 && b != a-1 {
+		return false
+	}
+	for _, s := range seqGroups {
+		if a >= s[0] && a <= s[1] && b >= s[0] && b <= s[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// runState is the run of identical characters and the sequence at the end of
+// a password. The zero runState is an empty password.
+type runState struct {
+	prev rune
+	rep  uint64 // identical characters in a row, ending with prev
+	seq  uint64 // length of the sequence ending with prev
+	dir  rune   // 1 for an ascending sequence, -1 for descending
+}
+
+// next returns the runState after appending r
+func (s runState) next(r rune) runState {
+	n := runState{prev: r, rep: 1, seq: 1}
+	switch {
+	case s.rep == 0:
+	case r == s.prev:
+		n.rep = s.rep + 1
+	case sequential(s.prev, r):
+		n.dir = r - s.prev
+		n.seq = 2
+		if n.dir == s.dir {
+			n.seq = s.seq + 1
+		}
+	}
+	return n
+}
+
+// runs returns the longest run of identical characters in pw, and the longest
+// ascending or descending sequence such as abc or 987.
+func runs(pw []rune) (repeat, sequence uint64) {
+	var s runState
+	for _, r := range pw {
+		s = s.next(r)
+		if s.rep > repeat {
+			repeat = s.rep
+		}
+		if s.seq > sequence {
+			sequence = s.seq
+		}
+	}
+	return
+}
+
+// limitsRuns returns true if MaxRepeat or MaxSequence is set
+func (g *GenOpts) limitsRuns() bool {
+	return g.MaxRepeat != 0 || g.MaxSequence != 0
+}
+
+// allowedRun returns true if the run at the end of a password is within the
+// MaxRepeat and MaxSequence limits
+func (g *GenOpts) allowedRun(s runState) bool {
+	if g.MaxRepeat != 0 && s.rep > g.MaxRepeat {
+		return false
+	}
+	if g.MaxSequence != 0 && s.seq > g.MaxSequence {
+		return false
+	}
+	return true
+}
+
+// fillRuns generates a password of length from classes validated by classes,
+// from the first character to the last. Each character is drawn from those
+// which keep the classes possible to fill and the runs within the limits.
+// If a character leaves no choice for a later position, it is removed and
+// another is drawn. Positions which can not be completed are remembered, so
+// an impossible password is rejected without trying every password, and
+// false is returned.
+func (g *GenOpts) fillRuns(h *hashStream, cs []*class, a [][]bool, length uint64) ([]rune, bool) {
+	pw := make([]rune, length)
+	dead := map[string]bool{}
+	var fill func(p uint64, s runState) bool
+	fill = func(p uint64, s runState) bool {
+		if p == length {
+			return true
+		}
+		key := fmt.Sprint(p, s)
+		for _, c := range cs {
+			key += fmt.Sprint(",", c.cur)
+		}
+		if dead[key] {
+			return false
+		}
+
+		var pool chars
+		var owner []*class
+		for j, c := range cs {
+			if !a[p][j] || c.cur >= c.max {
+				continue
+			}
+			pw[p] = c.chars[0]
+			c.cur++
+			ok := g.feasible(cs, a, pw)
+			pw[p] = 0
+			c.cur--
+			if !ok {
+				continue
+			}
+			for _, r := range c.chars {
+				if g.allowedRun(s.next(r)) {
+					pool = append(pool, r)
+					owner = append(owner, c)
+				}
+			}
+		}
+
+		for len(pool) > 0 {
+			j := h.uniform(uint64(len(pool)))
+			pw[p] = pool[j]
+			owner[j].cur++
+			if fill(p+1, s.next(pool[j])) {
+				return true
+			}
+			pw[p] = 0
+			owner[j].cur--
+			pool = append(pool[:j], pool[j+1:]...)
+			owner = append(owner[:j], owner[j+1:]...)
+		}
+		dead[key] = true
+		return false
+	}
+	return pw, fill(0, runState{})
+}
@@ -0,0 +1,78 @@
+package dpass
+
+import (
+	"crypto/ed25519"
+	"encoding/pem"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshKeyV2 derives an ed25519 key from a seed read from the hashStream.
+func sshKeyV2(g *GenOpts, h *hashStream) ([]byte, error) {
+	if err := g.onlyChars(false); err != nil {
+		return nil, err
+	}
+	seed := make([]byte, ed25519.SeedSize)
+	h.Read(seed)
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// sshComment is the comment of the keys of the options, username@domain
+func (g *GenOpts) sshComment() string {
+	return g.Username + "@" + g.Domain
+}
+
+// genSSHV2 returns the authorized_keys line of the ed25519 key of the options
+func genSSHV2(g *GenOpts, h *hashStream) (string, error) {
+	k, err := sshKeyV2(g, h)
+	if err != nil {
+		return "", err
+	}
+	return AuthorizedKey(k, g.sshComment())
+}
+
+// SSHKey will generate the deterministic ed25519 key for options of ModeSSH.
+// Since the Mode is part of the hashStream seed, the key is unrelated to the
+// password of the same options in any other mode.
+func (m *MasterKey) SSHKey(g *GenOpts) (ed25519.PrivateKey, error) {
+	k, err := m.derive(g, ModeSSH)
+	return ed25519.PrivateKey(k), err
+}
+
+// SSHKey will generate the ed25519 key based on the initialized options and
+// hashed master password.
+func (g *GenOpts) SSHKey() (ed25519.PrivateKey, error) {
+	m, err := g.masterKey()
+	if err != nil {
+		return nil, err
+	}
+	return m.SSHKey(g)
+}
+
+// SSHPrivateKey returns the ed25519 key of the options in the OpenSSH private
+// key format, commented with username@domain. The key is not encrypted.
+func (g *GenOpts) SSHPrivateKey() ([]byte, error) {
+	k, err := g.SSHKey()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ssh.MarshalPrivateKey(k, g.sshComment())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(b), nil
+}
+
+// AuthorizedKey returns the authorized_keys line of the public key of k
+func AuthorizedKey(k ed25519.PrivateKey, comment string) (string, error) {
+	p, err := ssh.NewPublicKey(k.Public())
+	if err != nil {
+		return "", err
+	}
+	l := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(p)), "\n")
+	if comment != "" {
+		l += " " + comment
+	}
+	return l, nil
+}
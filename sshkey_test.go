@@ -0,0 +1,54 @@
+package dpass
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHKey(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	g.Mode = ModeSSH
+	assert.NoError(g.HashPw([]byte(testPw)))
+
+	k, err := g.SSHKey()
+	assert.NoError(err)
+	l, err := g.SSHKey()
+	assert.NoError(err)
+	assert.Equal(k, l)
+
+	// The private key round trips through the OpenSSH format
+	b, err := g.SSHPrivateKey()
+	assert.NoError(err)
+	p, err := ssh.ParseRawPrivateKey(b)
+	assert.NoError(err)
+	assert.Equal(k, *p.(*ed25519.PrivateKey))
+
+	// GenPW returns the matching authorized_keys line
+	line, err := g.GenPW()
+	assert.NoError(err)
+	assert.Equal("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAID2OMUEFEFLsrXwnjmjN2Hdrthavg54raMxTxLg0qUpj foo@foo.com", line)
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	assert.NoError(err)
+	assert.Equal("foo@foo.com", comment)
+	sp, err := ssh.NewPublicKey(k.Public())
+	assert.NoError(err)
+	assert.Equal(sp.Marshal(), pub.Marshal())
+
+	g.Iteration = 1
+	l, err = g.SSHKey()
+	assert.NoError(err)
+	assert.NotEqual(k, l)
+
+	g.Mode = ModeKey
+	_, err = g.SSHKey()
+	assert.Error(err)
+
+	g.Mode = ModeSSH
+	g.GenVersion = 1
+	_, err = GenPW(g, []byte(testPw))
+	assert.Error(err, "v1 does not support ssh keys")
+}
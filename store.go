@@ -0,0 +1,205 @@
+package dpass
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned by a Store when no blob exists for an index
+var ErrNotFound = errors.New("Blob not found")
+
+// Store saves encrypted option blobs under their BlobIndex.
+// A Store never sees plaintext options, only the output of Blob and BlobIndex.
+type Store interface {
+	// Put saves blob under idx, replacing any existing blob
+	Put(idx, blob string) error
+	// Get returns the blob saved under idx
+	Get(idx string) (string, error)
+	// List returns every saved index which begins with prefix, usually a
+	// BlobIndexPrefix
+	List(prefix string) ([]string, error)
+	// Delete removes the blob saved under idx
+	Delete(idx string) error
+}
+
+// Save stores the encrypted options in s and returns the index it was saved under
+func (g *GenOpts) Save(s Store) (string, error) {
+	m, err := g.masterKey()
+	if err != nil {
+		return "", err
+	}
+	return m.Save(s, g)
+}
+
+// Save stores the options encrypted with the master key in s and returns the
+// index it was saved under
+func (m *MasterKey) Save(s Store, g *GenOpts) (string, error) {
+	idx, err := m.BlobIndex(g)
+	if err != nil {
+		return "", err
+	}
+	b, err := m.Blob(g)
+	if err != nil {
+		return "", err
+	}
+	return idx, s.Put(idx, b)
+}
+
+// Load decrypts every entry in s for the domain of g, keyed by index.
+// g must have its Domain set and a master password hashed.
+func (g *GenOpts) Load(s Store) (map[string]*GenOpts, error) {
+	m, err := g.masterKey()
+	if err != nil {
+		return nil, err
+	}
+	return m.Load(s, g.Domain)
+}
+
+// Load decrypts every entry in s for the domain saved with the master key,
+// keyed by index.
+func (m *MasterKey) Load(s Store, dom string) (map[string]*GenOpts, error) {
+	idxs, err := s.List(m.BlobIndexPrefix(dom))
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string]*GenOpts, len(idxs))
+	for _, idx := range idxs {
+		b, err := s.Get(idx)
+		if err != nil {
+			return nil, err
+		}
+		o, err := m.OpenBlob(dom, idx, b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", idx, err)
+		}
+		r[idx] = o
+	}
+	return r, nil
+}
+
+// The base32 extended alphabet used by BlobIndex
+const indexChars = "0123456789ABCDEFGHIJKLMNOPQRSTUV"
+
+// normIndex upper cases an index and ensures it is safe to use as a file name
+func normIndex(idx string) (string, error) {
+	idx = strings.ToUpper(idx)
+	for _, r := range idx {
+		if !strings.ContainsRune(indexChars, r) {
+			return "", fmt.Errorf("Invalid blob index %q", idx)
+		}
+	}
+	return idx, nil
+}
+
+// DirStore is a Store which keeps one file per BlobIndex in a directory.
+// Index names only use the case insensitive base32 extended alphabet, so the
+// directory can be synced across any filesystem.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a DirStore in dir, creating it if it does not exist
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+func (d *DirStore) path(idx string) (string, error) {
+	idx, err := normIndex(idx)
+	if err != nil {
+		return "", err
+	}
+	if idx == "" {
+		return "", fmt.Errorf("Blob index required")
+	}
+	return filepath.Join(d.dir, idx), nil
+}
+
+// Put writes the blob to a temporary file and renames it into place, so a
+// reader never sees a partially written blob.
+func (d *DirStore) Put(idx, blob string) error {
+	p, err := d.path(idx)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(d.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteString(blob); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (d *DirStore) Get(idx string) (string, error) {
+	p, err := d.path(idx)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (d *DirStore) List(prefix string) ([]string, error) {
+	prefix, err := normIndex(prefix)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var idxs []string
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		// Skip temporary and foreign files
+		idx, err := normIndex(fi.Name())
+		if err != nil || !strings.HasPrefix(idx, prefix) {
+			continue
+		}
+		idxs = append(idxs, idx)
+	}
+	sort.Strings(idxs)
+	return idxs, nil
+}
+
+func (d *DirStore) Delete(idx string) error {
+	p, err := d.path(idx)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package dpass
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "dpass")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	s, err := NewDirStore(filepath.Join(dir, "vault"))
+	assert.NoError(err)
+
+	g := newG1Opts()
+	assert.NoError(g.HashPw([]byte(testPw)))
+	idx, err := g.Save(s)
+	assert.NoError(err)
+
+	fi, err := os.Stat(filepath.Join(dir, "vault", idx))
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), fi.Mode().Perm())
+
+	o := newG1Opts()
+	o.Username = "bar"
+	_, err = o.Save(s)
+	assert.Error(err, "master password not hashed")
+	assert.NoError(o.HashPw([]byte(testPw)))
+	oidx, err := o.Save(s)
+	assert.NoError(err)
+
+	idxs, err := s.List(g.mk.BlobIndexPrefix(g.Domain))
+	assert.NoError(err)
+	assert.Len(idxs, 2)
+
+	es, err := g.Load(s)
+	assert.NoError(err)
+	assert.Equal(g, es[idx])
+	assert.Equal(o, es[oidx])
+
+	assert.NoError(s.Delete(idx))
+	_, err = s.Get(idx)
+	assert.Equal(ErrNotFound, err)
+	assert.Equal(ErrNotFound, s.Delete(idx))
+
+	_, err = s.Get("../etc/passwd")
+	assert.Error(err)
+}
@@ -0,0 +1,49 @@
+package dpass
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SymbolProfiles are symbol sets which are safe to use unquoted in a context.
+// A password from a profile can be pasted into that context without escaping.
+var SymbolProfiles = map[string]string{
+	// Unquoted words in POSIX shells, without globs, expansions or operators.
+	// A word starting with = is a command path in zsh.
+	"shell-safe": "@%_+-,./:",
+	// The unreserved characters of RFC 3986
+	"url-safe": "-._~",
+	// JSON strings, without the quote and backslash
+	"json-safe": "~!@#$%^&*()_+-=[]{}|;:,.<>/?'",
+	// XML text and attribute values, without the escaped characters <>&'"
+	"xml-safe": "~!@#$%^*()_+-=[]{}|;:,./?",
+	// SQL string literals and LIKE patterns, without quotes, comments and wildcards
+	"sql-safe": "~!@#$^*+=,./?:",
+	// LDAP distinguished names, without the characters escaped by RFC 4514
+	"ldap-dn-safe": "~!@$%^*_-./?:",
+	// Windows cmd.exe arguments, without operators, escapes and expansions
+	"windows-cmd-safe": "@#$_+-.:~",
+}
+
+// SymbolProfileNames returns the names of the SymbolProfiles in order
+func SymbolProfileNames() []string {
+	var ns []string
+	for n := range SymbolProfiles {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// SetSymbolProfile sets the SymbolSet to a profile from SymbolProfiles, and
+// records its name in the options. Characters which must never be used can
+// still be removed from the profile with Exclude.
+func (g *GenOpts) SetSymbolProfile(name string) error {
+	s, ok := SymbolProfiles[name]
+	if !ok {
+		return fmt.Errorf("Unknown symbol profile %s", name)
+	}
+	g.SymbolProfile = name
+	g.SymbolSet = s
+	return nil
+}
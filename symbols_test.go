@@ -0,0 +1,64 @@
+package dpass
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolProfiles(t *testing.T) {
+	assert := assert.New(t)
+	unsafe := map[string]string{
+		"shell-safe":       "`$\"'\\!*?[]{}()<>|&;#~^= ",
+		"url-safe":         "/?#[]@!$&'()*+,;=% ",
+		"json-safe":        "\"\\ ",
+		"xml-safe":         "<>&'\" ",
+		"sql-safe":         "'\";-%_\\ ",
+		"ldap-dn-safe":     ",+\"\\<>;=# ",
+		"windows-cmd-safe": "&|<>^%!\"(),;= ",
+	}
+	assert.Len(unsafe, len(SymbolProfiles))
+	for n, s := range SymbolProfiles {
+		assert.Equal(string(uniq(s)), s, n)
+		for _, r := range s {
+			assert.True(r > ' ' && r < 0x7f, n)
+			assert.False(strings.ContainsRune("0123456789", r) || (r|0x20 >= 'a' && r|0x20 <= 'z'), n)
+		}
+		assert.Equal(0, countIn(s, unsafe[n]), n)
+	}
+}
+
+func TestSetSymbolProfile(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	assert.NoError(g.SetSymbolProfile("url-safe"))
+	assert.Equal("url-safe", g.SymbolProfile)
+	assert.Equal("-._~", g.SymbolSet)
+
+	// The banned characters override the profile
+	g.Exclude = "~"
+	g.Symbols = 4
+	for i := uint64(0); i < 10; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.True(countIn(pw, "-._") >= 4, pw)
+		assert.Equal(0, countIn(pw, "~"), pw)
+		assert.Equal(0, countIn(pw, "!@#$%^*+=;,/?"), pw)
+	}
+
+	j, err := g.JSON()
+	assert.NoError(err)
+	o, err := FromJSON(j)
+	assert.NoError(err)
+	assert.Equal(g.SymbolProfile, o.SymbolProfile)
+	assert.Equal(g.SymbolSet, o.SymbolSet)
+	assert.Equal(g.Exclude, o.Exclude)
+
+	assert.Error(g.SetSymbolProfile("nope"))
+	assert.Equal("url-safe", g.SymbolProfile)
+}
@@ -0,0 +1,94 @@
+package dpass
+
+import (
+	"fmt"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// unicodeBlocks maps each GenVersion to the ranges of characters which can be
+// added to a CharClass by name. Only the characters of a range which are safe
+// in a password are used, see singleChar. Like the generators, the blocks of
+// a released version must never change.
+var unicodeBlocks = map[uint64]map[string][2]rune{
+	2: {
+		"latin1":      {0x00C0, 0x00FF}, // Latin-1 Supplement letters
+		"latin-ext-a": {0x0100, 0x017F}, // Latin Extended-A
+		"greek":       {0x0386, 0x03CE},
+		"cyrillic":    {0x0400, 0x045F},
+		"emoji":       {0x1F600, 0x1F64F}, // Emoticons
+	},
+}
+
+// singleChar returns true if r is a visible character which is its own grapheme
+// cluster and unchanged by NFC normalization.
+// Since marks, joiners and modifiers are rejected, a password of these
+// characters is NFC normalized and its length in runes is its length in
+// grapheme clusters.
+func singleChar(r rune) bool {
+	if !unicode.IsGraphic(r) || unicode.IsSpace(r) || unicode.In(r, unicode.M) {
+		return false
+	}
+	// Regional indicators pair into flags, and the skin tone modifiers attach
+	// to the emoji before them.
+	if (r >= 0x1F1E6 && r <= 0x1F1FF) || (r >= 0x1F3FB && r <= 0x1F3FF) {
+		return false
+	}
+	s := string(r)
+	return norm.NFC.String(s) == s && norm.NFC.PropertiesString(s).BoundaryBefore()
+}
+
+// blockChars returns the usable characters of a unicode block of a GenVersion
+func blockChars(version uint64, name string) (chars, error) {
+	b, ok := unicodeBlocks[version][name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown unicode block %s", name)
+	}
+	var c chars
+	for r := b[0]; r <= b[1]; r++ {
+		if !singleChar(r) {
+			continue
+		}
+		// Only letters from the alphabets, the symbols in them are mostly
+		// punctuation which is easily confused with ascii.
+		if name != "emoji" && !unicode.IsLetter(r) {
+			continue
+		}
+		c = append(c, r)
+	}
+	return c, nil
+}
+
+// setChars returns the characters of s without duplicates, what names s in
+// the error if any of them is not a space or a singleChar
+func setChars(what, s string) (chars, error) {
+	c := uniq(s)
+	for _, r := range c {
+		if r != ' ' && !singleChar(r) {
+			return nil, fmt.Errorf("%s contains %q which is not a single normalized character", what, r)
+		}
+	}
+	return c, nil
+}
+
+// classChars returns the characters of a CharClass, with the unicode blocks of
+// a GenVersion
+func (cc CharClass) classChars(version uint64) (chars, error) {
+	c, err := setChars("Character class "+cc.Name, cc.Chars)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range cc.Blocks {
+		bc, err := blockChars(version, b)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range bc {
+			if c.index(r) == -1 {
+				c = append(c, r)
+			}
+		}
+	}
+	return c, nil
+}
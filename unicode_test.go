@@ -0,0 +1,73 @@
+package dpass
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestBlockChars(t *testing.T) {
+	assert := assert.New(t)
+	for name := range unicodeBlocks[2] {
+		c, err := blockChars(2, name)
+		assert.NoError(err)
+		assert.NotEmpty(c, name)
+	}
+	g, err := blockChars(2, "greek")
+	assert.NoError(err)
+	assert.NotEqual(-1, g.index('λ'))
+	assert.Equal(-1, g.index(0x0387), "ano teleia is not a letter and changes under NFC")
+	l, err := blockChars(2, "latin1")
+	assert.NoError(err)
+	assert.Equal(-1, l.index('×'))
+	_, err = blockChars(2, "klingon")
+	assert.Error(err)
+	_, err = blockChars(1, "greek")
+	assert.Error(err, "v1 has no unicode blocks")
+}
+
+func TestUnicodeClasses(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.Length = 20
+	g.Classes = []CharClass{
+		{Name: "greek", Blocks: []string{"greek"}, Min: 2, Max: -1},
+		{Name: "emoji", Blocks: []string{"emoji"}, Min: 1, Max: 3},
+	}
+	for i := uint64(0); i < 10; i++ {
+		g.Iteration = i
+		pw, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.True(norm.NFC.IsNormalString(pw), pw)
+		assert.Equal(20, utf8.RuneCountInString(pw), pw)
+	}
+
+	// A decomposed é is two runes, and would not count as one character
+	g.Classes = []CharClass{{Name: "accents", Chars: "e\u0301", Max: -1}}
+	_, err = m.GenPW(g)
+	assert.Contains(err.Error(), "not a single normalized character")
+}
+
+func TestUnicodeSymbolSet(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newG1Opts()
+	g.SymbolSet = "!\u00e9"
+	_, err = m.GenPW(g)
+	assert.NoError(err)
+
+	// The symbols are checked like the characters of a class
+	g.SymbolSet = "!e\u0301"
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Symbol set contains '\u0301' which is not a single normalized character")
+	g.SymbolSet = "!\U0001F1FA"
+	_, err = m.GenPW(g)
+	assert.Error(err)
+}
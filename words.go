@@ -0,0 +1,176 @@
+package dpass
+
+import (
+	"bufio"
+	"crypto/sha512"
+	_ "embed"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Capitalization of the words in a passphrase
+const (
+	WordCaseLower  = "" // all lower case
+	WordCaseUpper  = "upper"
+	WordCaseTitle  = "title"  // first letter of each word upper case
+	WordCaseRandom = "random" // each word is randomly lower or title case
+)
+
+// What to append to the end of a passphrase
+const (
+	WordAppendNone   = ""
+	WordAppendDigit  = "digit"
+	WordAppendSymbol = "symbol" // a character from SymbolSet
+	WordAppendBoth   = "both"   // a digit followed by a symbol
+)
+
+//go:embed eff_large_wordlist.txt
+var effLargeWordlistTxt string
+
+var (
+	effLargeWordlist     []string
+	effLargeWordlistOnce sync.Once
+)
+
+// EFFLargeWordlist returns the EFF large word list, used by ModeWords unless a
+// custom word list is set.
+func EFFLargeWordlist() []string {
+	effLargeWordlistOnce.Do(func() {
+		var err error
+		effLargeWordlist, err = ParseWordlist(strings.NewReader(effLargeWordlistTxt))
+		if err != nil {
+			panic(err)
+		}
+	})
+	return effLargeWordlist
+}
+
+// ParseWordlist reads a word list with one word per line. Anything before the
+// last field of a line is ignored, so diceware lists with dice numbers can be
+// used directly.
+func ParseWordlist(r io.Reader) ([]string, error) {
+	var ws []string
+	seen := map[string]bool{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 {
+			continue
+		}
+		w := f[len(f)-1]
+		if seen[w] {
+			return nil, fmt.Errorf("Word list contains %q twice", w)
+		}
+		seen[w] = true
+		ws = append(ws, w)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(ws) < 2 {
+		return nil, fmt.Errorf("Word list must contain at least 2 words")
+	}
+	return ws, nil
+}
+
+func wordlistHash(ws []string) string {
+	h := sha512.Sum512_256([]byte(strings.Join(ws, "\n")))
+	return base32.HexEncoding.EncodeToString(h[:10])
+}
+
+// SetWordlist sets a custom word list for ModeWords and records its hash in
+// the options. The word list is not saved with the options, so it must be set
+// again with the same words before generating from saved options.
+func (g *GenOpts) SetWordlist(ws []string) {
+	g.wordlist = ws
+	g.WordlistHash = wordlistHash(ws)
+}
+
+// LoadWordlist sets the custom word list for options which were saved with one.
+// It returns an error if the words are not the list the options were saved with.
+func (g *GenOpts) LoadWordlist(ws []string) error {
+	if h := wordlistHash(ws); h != g.WordlistHash {
+		return fmt.Errorf("Word list %s does not match the options word list %s", h, g.WordlistHash)
+	}
+	g.wordlist = ws
+	return nil
+}
+
+func (g *GenOpts) words() ([]string, error) {
+	if g.WordlistHash == "" {
+		return EFFLargeWordlist(), nil
+	}
+	if g.wordlist == nil {
+		return nil, fmt.Errorf("Word list %s has not been loaded", g.WordlistHash)
+	}
+	return g.wordlist, nil
+}
+
+func title(w string) string {
+	r := []rune(w)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// genWordsV2 generates a passphrase of Words words joined by WordSep.
+func genWordsV2(g *GenOpts, h *hashStream) (string, error) {
+	if err := g.onlyChars(true); err != nil {
+		return "", err
+	}
+	if g.Words == 0 {
+		return "", fmt.Errorf("Word count must be greater than 0")
+	}
+	wl, err := g.words()
+	if err != nil {
+		return "", err
+	}
+
+	ws := make([]string, g.Words)
+	for i := range ws {
+		w := wl[h.uniform(uint64(len(wl)))]
+		switch g.WordCase {
+		case WordCaseLower:
+		case WordCaseUpper:
+			w = strings.ToUpper(w)
+		case WordCaseTitle:
+			w = title(w)
+		case WordCaseRandom:
+			if h.uniform(2) == 1 {
+				w = title(w)
+			}
+		default:
+			return "", fmt.Errorf("Unknown word case %q", g.WordCase)
+		}
+		ws[i] = w
+	}
+	pw := strings.Join(ws, g.WordSep)
+
+	digit := charRange('0', '9').remove(g.excluded()...)
+	sym := chars(g.SymbolSet).remove(g.excluded()...)
+	if len(digit) == 0 && (g.WordAppend == WordAppendDigit || g.WordAppend == WordAppendBoth) {
+		return "", fmt.Errorf("Every number is excluded")
+	}
+	switch g.WordAppend {
+	case WordAppendNone:
+	case WordAppendDigit:
+		pw += string(digit[h.uniform(uint64(len(digit)))])
+	case WordAppendSymbol, WordAppendBoth:
+		if g.SymbolSet == "" {
+			return "", fmt.Errorf("Symbol set required to append a symbol")
+		}
+		if len(sym) == 0 {
+			return "", fmt.Errorf("Every symbol of the symbol set is excluded")
+		}
+		if g.WordAppend == WordAppendBoth {
+			pw += string(digit[h.uniform(uint64(len(digit)))])
+		}
+		pw += string(sym[h.uniform(uint64(len(sym)))])
+	default:
+		return "", fmt.Errorf("Unknown word append %q", g.WordAppend)
+	}
+	return pw, nil
+}
@@ -0,0 +1,110 @@
+package dpass
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newWordsOpts() *GenOpts {
+	g := newG1Opts()
+	g.Mode = ModeWords
+	g.Words = 6
+	g.WordSep = "-"
+	return g
+}
+
+func TestEFFLargeWordlist(t *testing.T) {
+	wl := EFFLargeWordlist()
+	assert.Len(t, wl, 7776)
+	assert.Equal(t, "abacus", wl[0])
+	assert.Equal(t, "zoom", wl[len(wl)-1])
+}
+
+func TestWords(t *testing.T) {
+	assert := assert.New(t)
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+
+	g := newWordsOpts()
+	pw, err := m.GenPW(g)
+	assert.NoError(err)
+	assert.Equal("culminate-banshee-glaring-roamer-uncorrupt-produce", pw)
+	ws := strings.Split(pw, "-")
+	assert.Len(ws, 6)
+	for _, w := range ws {
+		assert.Contains(EFFLargeWordlist(), w)
+	}
+	again, err := m.GenPW(newWordsOpts())
+	assert.NoError(err)
+	assert.Equal(pw, again)
+
+	g.WordCase = WordCaseTitle
+	g.WordAppend = WordAppendBoth
+	g.SymbolSet = "!"
+	tpw, err := m.GenPW(g)
+	assert.NoError(err)
+	assert.True(strings.HasSuffix(tpw, "!"), tpw)
+	assert.Equal(strings.ToLower(tpw[:len(pw)]), pw)
+
+	// Excluded characters are never appended
+	g.SymbolSet = "!@#"
+	g.Exclude = "!#0123456789"
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Every number is excluded")
+	g.Exclude = "!#012345678"
+	for i := uint64(0); i < 20; i++ {
+		g.Iteration = i
+		xpw, err := m.GenPW(g)
+		assert.NoError(err)
+		assert.True(strings.HasSuffix(xpw, "9@"), xpw)
+	}
+	g.Iteration = 0
+	g.Exclude = "!@#"
+	_, err = m.GenPW(g)
+	assert.EqualError(err, "Every symbol of the symbol set is excluded")
+	g.WordAppend = WordAppendDigit
+	_, err = m.GenPW(g)
+	assert.NoError(err)
+	g.Exclude = ""
+
+	g.WordCase = "shouting"
+	_, err = m.GenPW(g)
+	assert.Error(err)
+
+	g = newWordsOpts()
+	g.GenVersion = 1
+	m1, err := NewMasterKey([]byte(testPw), LegacyKDF)
+	assert.NoError(err)
+	_, err = m1.GenPW(g)
+	assert.Error(err, "v1 only supports ModeChars")
+}
+
+func TestCustomWordlist(t *testing.T) {
+	assert := assert.New(t)
+	wl, err := ParseWordlist(strings.NewReader("1 alpha\n2 beta\n\n3 gamma\n"))
+	assert.NoError(err)
+	assert.Equal([]string{"alpha", "beta", "gamma"}, wl)
+	_, err = ParseWordlist(strings.NewReader("alpha\nalpha\n"))
+	assert.Error(err)
+
+	m, err := NewMasterKey([]byte(testPw), DefaultKDF)
+	assert.NoError(err)
+	g := newWordsOpts()
+	g.SetWordlist(wl)
+	pw, err := m.GenPW(g)
+	assert.NoError(err)
+
+	j, err := g.JSON()
+	assert.NoError(err)
+	o, err := FromJSON(j)
+	assert.NoError(err)
+	_, err = m.GenPW(o)
+	assert.Error(err, "word list not loaded")
+	assert.Error(o.LoadWordlist([]string{"alpha", "beta"}))
+	assert.NoError(o.LoadWordlist(wl))
+	opw, err := m.GenPW(o)
+	assert.NoError(err)
+	assert.Equal(pw, opw)
+}
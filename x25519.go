@@ -0,0 +1,94 @@
+package dpass
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// Formats of the X25519 keys generated by ModeX25519
+const (
+	X25519FormatAge       = ""          // an age identity and recipient
+	X25519FormatWireGuard = "wireguard" // base64 WireGuard private and public keys
+)
+
+// x25519V2 reads a private key from the hashStream, clamped as by wg genkey
+// so it is the same in every format.
+func x25519V2(g *GenOpts, h *hashStream) ([]byte, error) {
+	if err := g.onlyChars(false); err != nil {
+		return nil, err
+	}
+	k := make([]byte, curve25519.ScalarSize)
+	h.Read(k)
+	k[0] &= 248
+	k[31] &= 127
+	k[31] |= 64
+	return k, nil
+}
+
+// genX25519V2 returns the private key of the options in the X25519Format
+func genX25519V2(g *GenOpts, h *hashStream) (string, error) {
+	k, err := x25519V2(g, h)
+	if err != nil {
+		return "", err
+	}
+	switch g.X25519Format {
+	case X25519FormatAge:
+		return AgeIdentity(k)
+	case X25519FormatWireGuard:
+		return WireGuardKey(k), nil
+	}
+	return "", fmt.Errorf("Unknown X25519 format %q", g.X25519Format)
+}
+
+// X25519 will generate the deterministic X25519 private and public keys for
+// options of ModeX25519. The X25519Format does not change the keys.
+func (m *MasterKey) X25519(g *GenOpts) (priv, pub []byte, err error) {
+	priv, err = m.derive(g, ModeX25519)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err = curve25519.X25519(priv, curve25519.Basepoint)
+	return priv, pub, err
+}
+
+// X25519 will generate the X25519 keys based on the initialized options and
+// hashed master password.
+func (g *GenOpts) X25519() (priv, pub []byte, err error) {
+	m, err := g.masterKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.X25519(g)
+}
+
+// AgeIdentity returns the age identity of an X25519 private key
+func AgeIdentity(priv []byte) (string, error) {
+	s, err := bech32Encode("age-secret-key-", priv)
+	return strings.ToUpper(s), err
+}
+
+// AgeRecipient returns the age recipient of an X25519 public key
+func AgeRecipient(pub []byte) (string, error) {
+	return bech32Encode("age", pub)
+}
+
+// WireGuardKey returns an X25519 private or public key as used by WireGuard
+func WireGuardKey(k []byte) string {
+	return base64.StdEncoding.EncodeToString(k)
+}
+
+// WireGuardInterface returns the [Interface] section of a WireGuard config for
+// the private key. The address and listen port are left out if empty or 0.
+func WireGuardInterface(priv []byte, address string, listenPort uint16) string {
+	s := "[Interface]\n"
+	if address != "" {
+		s += fmt.Sprintf("Address = %s\n", address)
+	}
+	if listenPort != 0 {
+		s += fmt.Sprintf("ListenPort = %d\n", listenPort)
+	}
+	return s + fmt.Sprintf("PrivateKey = %s\n", WireGuardKey(priv))
+}
@@ -0,0 +1,80 @@
+package dpass
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestBech32(t *testing.T) {
+	assert := assert.New(t)
+	// Test vectors from BIP 173
+	s, err := bech32Encode("a", nil)
+	assert.NoError(err)
+	assert.Equal("a12uel5l", s)
+
+	d, _ := hex.DecodeString("00443214c74254b635cf84653a56d7c675be77df")
+	s, err = bech32Encode("abcdef", d)
+	assert.NoError(err)
+	assert.Equal("abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", s)
+
+	_, err = bech32Encode("A", nil)
+	assert.Error(err)
+}
+
+func TestX25519(t *testing.T) {
+	assert := assert.New(t)
+	g := newG1Opts()
+	g.Mode = ModeX25519
+	assert.NoError(g.HashPw([]byte(testPw)))
+
+	priv, pub, err := g.X25519()
+	assert.NoError(err)
+	assert.Len(priv, 32)
+	assert.Equal(byte(0), priv[0]&7)
+	assert.Equal(byte(64), priv[31]&192)
+	p, err := curve25519.X25519(priv, curve25519.Basepoint)
+	assert.NoError(err)
+	assert.Equal(p, pub)
+
+	id, err := g.GenPW()
+	assert.NoError(err)
+	assert.Equal("AGE-SECRET-KEY-1XZ6PXXH0QH4QZH8JPEPEDC6J466UV2GK80H0YFK2MJ0N0JYN5PTSAARS8E", id)
+	assert.True(strings.HasPrefix(id, "AGE-SECRET-KEY-1"), id)
+	assert.Equal(id, strings.ToUpper(id))
+	assert.Len(id, 74)
+	r, err := AgeRecipient(pub)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(r, "age1"), r)
+	assert.Len(r, 62)
+
+	// The format does not change the key
+	g.X25519Format = X25519FormatWireGuard
+	wg, err := g.GenPW()
+	assert.NoError(err)
+	assert.Equal("MLQTGu8F6gFc8g5DluNSrrXGKRY77vImytyfN8iToFc=", wg)
+	b, err := base64.StdEncoding.DecodeString(wg)
+	assert.NoError(err)
+	assert.Equal(priv, b)
+	assert.Equal("[Interface]\nAddress = 10.0.0.1/24\nListenPort = 51820\nPrivateKey = "+wg+"\n",
+		WireGuardInterface(priv, "10.0.0.1/24", 51820))
+	assert.Equal("[Interface]\nPrivateKey = "+wg+"\n", WireGuardInterface(priv, "", 0))
+
+	g.X25519Format = "pem"
+	_, err = g.GenPW()
+	assert.Error(err)
+
+	g.X25519Format = X25519FormatAge
+	g.Iteration = 1
+	p, _, err = g.X25519()
+	assert.NoError(err)
+	assert.NotEqual(priv, p)
+
+	g.Mode = ModeSSH
+	_, _, err = g.X25519()
+	assert.Error(err)
+}